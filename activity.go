@@ -0,0 +1,55 @@
+package todo
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	ActivityEntityList = "list"
+	ActivityEntityItem = "item"
+)
+
+// Actions recorded in the activity log.
+const (
+	ActionListCreated    = "list.created"
+	ActionListUpdated    = "list.updated"
+	ActionListMoved      = "list.moved"
+	ActionListArchived   = "list.archived"
+	ActionListUnarchived = "list.unarchived"
+	ActionListDeleted    = "list.deleted"
+	ActionListRestored   = "list.restored"
+
+	ActionItemCreated     = "item.created"
+	ActionItemUpdated     = "item.updated"
+	ActionItemCompleted   = "item.completed"
+	ActionItemReopened    = "item.reopened"
+	ActionItemMoved       = "item.moved"
+	ActionItemMovedToList = "item.moved_to_list"
+	ActionItemArchived    = "item.archived"
+	ActionItemUnarchived  = "item.unarchived"
+	ActionItemDeleted     = "item.deleted"
+	ActionItemRestored    = "item.restored"
+)
+
+// Activity is an entry of the activity log of a list: who did what to the list
+// or one of its items, and which fields it changed.
+type Activity struct {
+	Id       int     `json:"id" db:"id"`
+	ListId   int     `json:"list_id" db:"list_id"`
+	UserId   *int    `json:"user_id" db:"user_id"`
+	Username *string `json:"username" db:"username"`
+	Action   string  `json:"action" db:"action"`
+	Entity   string  `json:"entity" db:"entity"`
+	EntityId int     `json:"entity_id" db:"entity_id"`
+	// Changes maps the names of the changed fields to Change values.
+	Changes   json.RawMessage `json:"changes" db:"changes" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Change is the value of a field before and after a change, null when the
+// entity did not exist on that side of it.
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
@@ -0,0 +1,69 @@
+package todo
+
+import "fmt"
+
+const (
+	BulkCreate   = "create"
+	BulkUpdate   = "update"
+	BulkDelete   = "delete"
+	BulkComplete = "complete"
+)
+
+// BulkOperation is one change to the items of a list in a bulk request. Item
+// is the item to create, Update the fields an update changes, and Id the item
+// an update, delete or complete applies to.
+type BulkOperation struct {
+	Op     string           `json:"op" binding:"required"`
+	Id     int              `json:"id"`
+	Item   *TodoItem        `json:"item"`
+	Update *UpdateItemInput `json:"update"`
+	// Version, when set, is the version of the item the client last saw.
+	Version *int `json:"version"`
+}
+
+func (o BulkOperation) Validate() error {
+	switch o.Op {
+	case BulkCreate:
+		if o.Item == nil {
+			return NewError(ErrValidation, "create needs an item")
+		}
+	case BulkUpdate:
+		if o.Update == nil {
+			return NewError(ErrValidation, "update needs an update structure")
+		}
+		fallthrough
+	case BulkDelete, BulkComplete:
+		if o.Id == 0 {
+			return NewError(ErrValidation, o.Op+" needs an item id")
+		}
+	default:
+		return NewError(ErrValidation, "op must be one of create, update, delete, complete")
+	}
+	return nil
+}
+
+// BulkInput is a batch of operations applied all together or not at all.
+type BulkInput struct {
+	Operations []BulkOperation `json:"operations" binding:"required,dive"`
+}
+
+// BulkResult is the outcome of a bulk operation, Id is the item it created or changed.
+type BulkResult struct {
+	Op string `json:"op"`
+	Id int    `json:"id"`
+}
+
+// BulkError reports the operation that made a batch fail. It unwraps to the
+// error of that operation.
+type BulkError struct {
+	Index int
+	Err   error
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.Index, e.Err.Error())
+}
+
+func (e *BulkError) Unwrap() error {
+	return e.Err
+}
@@ -38,20 +38,60 @@ func main() {
 		logrus.Fatalf("error loading .env file: %s", err.Error())
 	}
 
-	db, err := repository.NewPostgresDB(repository.Config{
+	dbConfig := repository.Config{
 		Host:     viper.GetString("db.host"),
 		Port:     viper.GetString("db.port"),
 		Username: viper.GetString("db.username"),
 		Password: os.Getenv("DB_PASSWORD"),
 		DBName:   viper.GetString("db.dbname"),
 		SSLMode:  viper.GetString("db.sslmode"),
-	})
+	}
+
+	db, err := repository.NewPostgresDB(dbConfig)
 	if err != nil {
 		logrus.Fatalf("failed to initialize db: %s", err.Error())
 	}
 
+	hasher, err := service.NewPasswordHasher(viper.GetString("auth.password_hasher"))
+	if err != nil {
+		logrus.Fatalf("failed to initialize password hasher: %s", err.Error())
+	}
+
+	var keyConfigs []service.KeyConfig
+	if err := viper.UnmarshalKey("auth.signing.keys", &keyConfigs); err != nil {
+		logrus.Fatalf("error reading signing keys config: %s", err.Error())
+	}
+
+	keys, err := service.NewKeySet(viper.GetString("auth.signing.active_kid"), keyConfigs)
+	if err != nil {
+		logrus.Fatalf("failed to load signing keys: %s", err.Error())
+	}
+
+	var eventListener service.EventListener
+	if viper.GetBool("events.notify") {
+		eventListener = repository.NewEventListener(dbConfig)
+	}
+
 	repos := repository.NewRepository(db)
-	services := service.NewService(repos)
+	services := service.NewService(repos, service.Config{
+		PasswordHasher: hasher,
+		SigningKeys:    keys,
+		Pagination: service.Pagination{
+			DefaultLimit: viper.GetInt("pagination.default_limit"),
+			MaxLimit:     viper.GetInt("pagination.max_limit"),
+		},
+		BulkLimit:      viper.GetInt("bulk.max_operations"),
+		TrashRetention: viper.GetDuration("trash.retention"),
+		EventListener:  eventListener,
+		Webhooks: service.WebhookConfig{
+			Timeout:     viper.GetDuration("webhooks.timeout"),
+			MaxAttempts: viper.GetInt("webhooks.max_attempts"),
+			Backoff:     viper.GetDuration("webhooks.backoff"),
+			MaxBackoff:  viper.GetDuration("webhooks.max_backoff"),
+			BatchSize:   viper.GetInt("webhooks.batch_size"),
+		},
+		UndoWindow: viper.GetDuration("undo.window"),
+	})
 	handlers := handler.NewHandler(services)
 
 	srv := new(todo.Server)
@@ -61,6 +101,11 @@ func main() {
 		}
 	}()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	go services.Trash.RunPurger(ctx, viper.GetDuration("trash.purge_interval"))
+	go services.Events.Run(ctx)
+	go services.Webhook.RunDispatcher(ctx, viper.GetDuration("webhooks.dispatch_interval"))
+
 	logrus.Printf("TodoApp Started")
 
 	quit := make(chan os.Signal, 1)
@@ -69,6 +114,8 @@ func main() {
 
 	logrus.Printf("TodoApp Shutting Down")
 
+	cancel()
+
 	if err := srv.Shutdown(context.Background()); err != nil {
 		logrus.Errorf("error occured on server shutting down: %s", err.Error())
 	}
@@ -0,0 +1,32 @@
+package todo
+
+import "errors"
+
+// Kinds of failures the HTTP layer turns into status codes. Errors returned by
+// repositories and services wrap one of them, check with errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrForbidden    = errors.New("forbidden")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrPrecondition = errors.New("precondition failed")
+)
+
+type domainError struct {
+	kind    error
+	message string
+}
+
+func (e *domainError) Error() string {
+	return e.message
+}
+
+func (e *domainError) Unwrap() error {
+	return e.kind
+}
+
+// NewError returns an error of the given kind that reads as message.
+func NewError(kind error, message string) error {
+	return &domainError{kind: kind, message: message}
+}
@@ -0,0 +1,25 @@
+package todo
+
+import "time"
+
+const (
+	EventListCreated = "list.created"
+	EventListUpdated = "list.updated"
+	EventListDeleted = "list.deleted"
+	EventItemCreated = "item.created"
+	EventItemUpdated = "item.updated"
+	EventItemDeleted = "item.deleted"
+)
+
+// Event tells the members of a list that the list or one of its items changed.
+// It only names what changed, clients fetch the new state themselves.
+type Event struct {
+	Type   string `json:"type"`
+	ListId int    `json:"list_id"`
+	ItemId int    `json:"item_id,omitempty"`
+	// UserId is the user who made the change.
+	UserId int       `json:"user_id"`
+	At     time.Time `json:"at"`
+	// Recipients are the members of the list when the change was made.
+	Recipients []int `json:"-"`
+}
@@ -0,0 +1,8 @@
+package todo
+
+// Page asks for at most Limit rows following the row Cursor points at.
+// An empty Cursor starts from the beginning.
+type Page struct {
+	Limit  int
+	Cursor string
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+	"todo"
+	"todo/pkg/repository"
+	"todo/pkg/testdb"
+)
+
+// TestChangeAccess checks that changing the lists and items of other users or
+// ones that do not exist fails with the status the API promises, and that a
+// member without the needed role is told so.
+func TestChangeAccess(t *testing.T) {
+	db := testdb.Open(t)
+	lists := repository.NewTodoListPostgres(db)
+	items := repository.NewTodoItemPostgres(db)
+
+	ownerId := testdb.CreateUser(t, db, "owner")
+	viewerId := testdb.CreateUser(t, db, "viewer")
+	otherId := testdb.CreateUser(t, db, "other")
+
+	listId, err := lists.Create(ownerId, todo.TodoList{Title: "owner's list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemId, err := items.Create(ownerId, listId, todo.TodoItem{Title: "owner's item"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repository.NewListMemberPostgres(db).Add(listId, "viewer", todo.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+
+	otherListId, err := lists.Create(otherId, todo.TodoList{Title: "other's list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherItemId, err := items.Create(otherId, otherListId, todo.TodoItem{Title: "other's item"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const missingId = 1 << 30
+	title := "changed"
+	updateList := todo.UpdateListInput{Title: &title}
+	updateItem := todo.UpdateItemInput{Title: &title}
+
+	var journals int
+	journal := func() todo.Journal {
+		journals++
+		return todo.Journal{TokenHash: fmt.Sprintf("token-%d", journals), ExpiresAt: time.Now().Add(time.Hour)}
+	}
+
+	tests := []struct {
+		name   string
+		change func() error
+		want   int
+	}{
+		{"update list of another user", func() error { return lists.Update(ownerId, otherListId, updateList) }, http.StatusNotFound},
+		{"update list as viewer", func() error { return lists.Update(viewerId, listId, updateList) }, http.StatusForbidden},
+		{"update missing list", func() error { return lists.Update(ownerId, missingId, updateList) }, http.StatusNotFound},
+		{"delete list of another user", func() error { return lists.Delete(ownerId, otherListId, nil, journal()) }, http.StatusNotFound},
+		{"delete list as viewer", func() error { return lists.Delete(viewerId, listId, nil, journal()) }, http.StatusForbidden},
+		{"delete missing list", func() error { return lists.Delete(ownerId, missingId, nil, journal()) }, http.StatusNotFound},
+		{"update item of another user", func() error { return items.Update(ownerId, otherItemId, updateItem) }, http.StatusNotFound},
+		{"update item as viewer", func() error { return items.Update(viewerId, itemId, updateItem) }, http.StatusForbidden},
+		{"update missing item", func() error { return items.Update(ownerId, missingId, updateItem) }, http.StatusNotFound},
+		{"delete item of another user", func() error { return items.Delete(ownerId, otherItemId, nil, journal()) }, http.StatusNotFound},
+		{"delete item as viewer", func() error { return items.Delete(viewerId, itemId, nil, journal()) }, http.StatusForbidden},
+		{"delete missing item", func() error { return items.Delete(ownerId, missingId, nil, journal()) }, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.change()
+			if err == nil {
+				t.Fatal("change succeeded")
+			}
+
+			c, w := testContext("", "")
+			newServiceErrorResponse(c, err)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, err)
+			}
+		})
+	}
+
+	otherList, err := lists.GetById(otherId, otherListId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherItem, err := items.GetById(otherId, otherItemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otherList.Title != "other's list" || otherItem.Title != "other's item" {
+		t.Errorf("other user's list and item are now %q and %q", otherList.Title, otherItem.Title)
+	}
+
+	ownList, err := lists.GetById(ownerId, listId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ownList.Title != "owner's list" {
+		t.Errorf("viewer changed the list title to %q", ownList.Title)
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getActivityResponse struct {
+	Data       []todo.Activity `json:"data"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// @Summary Get List Activity
+// @Security ApiKeyAuth
+// @Tags activity
+// @Description get who changed what in a list and its items, latest first
+// @ID get-list-activity
+// @Produce  json
+// @Param id path int true "list id"
+// @Param limit query int false "page size"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} getActivityResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/activity [get]
+func (h *Handler) getListActivity(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	page, err := parsePage(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	activity, next, err := h.services.Activity.GetByList(userId, listId, page)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getActivityResponse{
+		Data:       activity,
+		NextCursor: next,
+	})
+}
+
+// @Summary Get Activity Feed
+// @Security ApiKeyAuth
+// @Tags activity
+// @Description get the activity of every list the user is a member of, latest first
+// @ID get-activity-feed
+// @Produce  json
+// @Param limit query int false "page size"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} getActivityResponse
+// @Failure 400 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/activity [get]
+func (h *Handler) getActivityFeed(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	page, err := parsePage(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	activity, next, err := h.services.Activity.GetFeed(userId, page)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getActivityResponse{
+		Data:       activity,
+		NextCursor: next,
+	})
+}
@@ -21,14 +21,14 @@ import (
 // @Router /auth/sign-up [post]
 func (h *Handler) signUp(c *gin.Context) {
 	var input todo.User
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
 		return
 	}
 
 	id, err := h.services.Authorization.CreateUser(input)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
@@ -49,25 +49,93 @@ type signInInput struct {
 // @Accept  json
 // @Produce  json
 // @Param input body signInInput true "credentials"
-// @Success 200 {string} string "token"
+// @Success 200 {object} tokensResponse
 // @Failure 400,404 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Failure default {object} errorResponse
 // @Router /auth/sign-in [post]
 func (h *Handler) signIn(c *gin.Context) {
 	var input signInInput
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
 		return
 	}
 
-	token, err := h.services.Authorization.GenerateToken(input.Username, input.Password)
+	tokens, err := h.services.Authorization.GenerateToken(input.Username, input.Password)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, map[string]interface{}{
-		"token": token,
+	c.JSON(http.StatusOK, tokensResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 	})
 }
+
+type tokensResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type refreshTokenInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// @Summary Refresh
+// @Tags auth
+// @Description exchange a refresh token for a new token pair
+// @ID refresh
+// @Accept  json
+// @Produce  json
+// @Param input body refreshTokenInput true "refresh token"
+// @Success 200 {object} tokensResponse
+// @Failure 400 {object} errorResponse
+// @Failure 401 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/refresh [post]
+func (h *Handler) refresh(c *gin.Context) {
+	var input refreshTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	tokens, err := h.services.Authorization.RefreshToken(input.RefreshToken)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokensResponse{
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+	})
+}
+
+// @Summary SignOut
+// @Tags auth
+// @Description revoke the session of a refresh token
+// @ID sign-out
+// @Accept  json
+// @Produce  json
+// @Param input body refreshTokenInput true "refresh token"
+// @Success 200 {object} statusResponse
+// @Failure 400 {object} errorResponse
+// @Failure 401 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /auth/sign-out [post]
+func (h *Handler) signOut(c *gin.Context) {
+	var input refreshTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.services.Authorization.SignOut(input.RefreshToken); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
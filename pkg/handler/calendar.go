@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const calendarContentType = "text/calendar; charset=utf-8"
+
+// @Summary Get List Calendar
+// @Security ApiKeyAuth
+// @Tags calendar
+// @Description export the items of a list that have a due date as iCalendar (RFC 5545) VTODO components with their status, priority, due date, completion and description
+// @ID get-list-calendar
+// @Produce  text/calendar
+// @Param id path int true "list id"
+// @Success 200 {string} string "iCalendar data"
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/calendar.ics [get]
+func (h *Handler) getListCalendar(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	calendar, err := h.services.Calendar.GetListCalendar(userId, listId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="list-%d.ics"`, listId))
+	c.Data(http.StatusOK, calendarContentType, calendar)
+}
+
+type calendarFeedResponse struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// @Summary Create Calendar Feed
+// @Security ApiKeyAuth
+// @Tags calendar
+// @Description create the URL calendar apps subscribe to for the items with a due date of all the lists of the user; anyone with the URL can read the feed, so creating a new one revokes the previous URL
+// @ID create-calendar-feed
+// @Produce  json
+// @Success 200 {object} calendarFeedResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/calendar/feed [post]
+func (h *Handler) createCalendarFeed(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	token, err := h.services.Calendar.CreateFeed(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, calendarFeedResponse{
+		Token: token,
+		URL:   fmt.Sprintf("%s/calendar/%s.ics", baseURL(c), token),
+	})
+}
+
+// @Summary Delete Calendar Feed
+// @Security ApiKeyAuth
+// @Tags calendar
+// @Description revoke the calendar feed URL of the user
+// @ID delete-calendar-feed
+// @Produce  json
+// @Success 200 {object} statusResponse
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/calendar/feed [delete]
+func (h *Handler) deleteCalendarFeed(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	if err := h.services.Calendar.DeleteFeed(userId); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Get Calendar Feed
+// @Tags calendar
+// @Description the calendar feed of a user as iCalendar (RFC 5545) VTODO components, authenticated by the secret token in its URL instead of a JWT
+// @ID get-calendar-feed
+// @Produce  text/calendar
+// @Param token path string true "feed token, optionally followed by .ics"
+// @Success 200 {string} string "iCalendar data"
+// @Failure 404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /calendar/{token} [get]
+func (h *Handler) getCalendarFeed(c *gin.Context) {
+	calendar, err := h.services.Calendar.GetFeed(strings.TrimSuffix(c.Param("token"), ".ics"))
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-cache")
+	c.Data(http.StatusOK, calendarContentType, calendar)
+}
+
+// baseURL is the scheme and host the request was made to, as seen by the client.
+func baseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag is the entity tag of a list or an item at the given version as it is
+// represented in body. Some of what a response holds changes without a new
+// version, like the position of an item, its subtask counts or the role of the
+// user in a list, so the tag also carries a digest of the whole body.
+func etag(version int, body interface{}) string {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return `"` + strconv.Itoa(version) + `"`
+	}
+	sum := sha256.Sum256(b)
+
+	return `"` + strconv.Itoa(version) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// notModified sets the ETag header for body at the given version and responds
+// with 304 when the client already holds it according to If-None-Match.
+func notModified(c *gin.Context, version int, body interface{}) bool {
+	tag := etag(version, body)
+	c.Header("ETag", tag)
+
+	for _, t := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == tag || t == "*" {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifMatchVersion returns the version a change is conditional on according to
+// If-Match, or nil when the change is unconditional. Only the version part of
+// the tag counts, as changes are checked against the version alone. Weak tags
+// never match, If-Match only compares strong ones.
+func ifMatchVersion(c *gin.Context) (*int, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	if strings.HasPrefix(header, "W/") {
+		return nil, todo.NewError(todo.ErrPrecondition, "weak entity tags do not match If-Match")
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return nil, errors.New("invalid If-Match header")
+	}
+	tag, _, _ := strings.Cut(header[1:len(header)-1], "-")
+	version, err := strconv.Atoi(tag)
+	if err != nil {
+		return nil, errors.New("invalid If-Match header")
+	}
+
+	return &version, nil
+}
+
+// newIfMatchErrorResponse responds to an error of ifMatchVersion, a malformed
+// header is a bad request.
+func newIfMatchErrorResponse(c *gin.Context, err error) {
+	if errors.Is(err, todo.ErrPrecondition) {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	newErrorResponse(c, http.StatusBadRequest, err.Error())
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testContext(header, value string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if header != "" {
+		c.Request.Header.Set(header, value)
+	}
+
+	return c, w
+}
+
+func TestNotModified(t *testing.T) {
+	item := todo.TodoItem{Id: 1, Title: "a", Version: 3}
+	moved := item
+	moved.Position = 2
+	tag := etag(item.Version, item)
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		body        todo.TodoItem
+		want        bool
+	}{
+		{"no header", "", item, false},
+		{"same", tag, item, true},
+		{"weak", "W/" + tag, item, true},
+		{"in list", `"1-00", ` + tag, item, true},
+		{"any", "*", item, true},
+		{"changed without version", tag, moved, false},
+		{"version only", `"3"`, item, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := testContext("If-None-Match", tt.ifNoneMatch)
+			if got := notModified(c, tt.body.Version, tt.body); got != tt.want {
+				t.Errorf("notModified = %v, want %v", got, tt.want)
+			}
+			if c.Writer.Header().Get("ETag") != etag(tt.body.Version, tt.body) {
+				t.Errorf("ETag = %q", c.Writer.Header().Get("ETag"))
+			}
+		})
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    *int
+		wantErr bool
+	}{
+		{"none", "", nil, false},
+		{"any", "*", nil, false},
+		{"version", `"7"`, intPtr(7), false},
+		{"etag", etag(7, todo.TodoItem{}), intPtr(7), false},
+		{"unquoted", "7", nil, true},
+		{"garbage", `"x-1"`, nil, true},
+		{"weak", "W/" + etag(7, todo.TodoItem{}), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := testContext("If-Match", tt.ifMatch)
+			got, err := ifMatchVersion(c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+				t.Errorf("version = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIfMatchErrorStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    int
+	}{
+		{"malformed", "7", http.StatusBadRequest},
+		{"weak", `W/"7"`, http.StatusPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := testContext("If-Match", tt.ifMatch)
+			_, err := ifMatchVersion(c)
+			if err == nil {
+				t.Fatal("If-Match accepted")
+			}
+			newIfMatchErrorResponse(c, err)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
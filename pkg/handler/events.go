@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// keepAliveInterval is how often an idle event stream gets a comment, so that
+// proxies do not close it.
+const keepAliveInterval = 30 * time.Second
+
+// @Summary Subscribe To Events
+// @Security ApiKeyAuth
+// @Tags events
+// @Description stream Server-Sent Events about the lists the user is a member of and their items; each event is named after its type (list.created, list.updated, list.deleted, item.created, item.updated, item.deleted) and carries a todo.Event as data. The stream ends if the client falls too far behind, clients reconnect and refetch then.
+// @ID subscribe-events
+// @Produce  text/event-stream
+// @Success 200 {object} todo.Event
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/events [get]
+func (h *Handler) subscribeEvents(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	events, unsubscribe := h.services.Events.Subscribe(userId)
+	defer unsubscribe()
+
+	// the stream outlives the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		newErrorResponse(c, http.StatusInternalServerError, "streaming is not supported: "+err.Error())
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		}
+	})
+}
@@ -23,10 +23,15 @@ func (h *Handler) InitRoutes() *gin.Engine {
 	router.StaticFile("/swagger.json", "./swagger.json")
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	router.GET("/.well-known/jwks.json", h.getJWKS)
+	router.GET("/calendar/:token", h.getCalendarFeed)
+
 	auth := router.Group("/auth")
 	{
 		auth.POST("/sign-up", h.signUp)
 		auth.POST("/sign-in", h.signIn)
+		auth.POST("/refresh", h.refresh)
+		auth.POST("/sign-out", h.signOut)
 	}
 
 	api := router.Group("/api", h.userIdentity)
@@ -38,19 +43,79 @@ func (h *Handler) InitRoutes() *gin.Engine {
 			lists.GET("/:id", h.getListById)
 			lists.PUT("/:id", h.updateList)
 			lists.DELETE("/:id", h.deleteList)
+			lists.PATCH("/:id/position", h.moveList)
+			lists.POST("/:id/duplicate", h.duplicateList)
+			lists.POST("/:id/archive", h.archiveList)
+			lists.DELETE("/:id/archive", h.unarchiveList)
+			lists.POST("/:id/restore", h.restoreList)
+			lists.GET("/:id/activity", h.getListActivity)
+			lists.GET("/:id/calendar.ics", h.getListCalendar)
 
 			items := lists.Group(":id/items")
 			{
 				items.POST("/", h.createItem)
 				items.GET("/", h.getAllItems)
+				items.POST("/bulk", h.bulkItems)
+			}
+
+			members := lists.Group(":id/members")
+			{
+				members.GET("/", h.getAllMembers)
+				members.POST("/", h.addMember)
+				members.PUT("/:user_id", h.updateMember)
+				members.DELETE("/:user_id", h.deleteMember)
+			}
+
+			invites := lists.Group(":id/invites")
+			{
+				invites.POST("/", h.createInvite)
+				invites.GET("/", h.getAllInvites)
+				invites.DELETE("/:invite_id", h.revokeInvite)
+			}
+
+			webhooks := lists.Group(":id/webhooks")
+			{
+				webhooks.POST("/", h.createWebhook)
+				webhooks.GET("/", h.getAllWebhooks)
+				webhooks.DELETE("/:webhook_id", h.deleteWebhook)
+				webhooks.GET("/:webhook_id/deliveries", h.getWebhookDeliveries)
+				webhooks.POST("/:webhook_id/deliveries/:delivery_id/redeliver", h.redeliverWebhook)
 			}
 		}
 
+		api.POST("/invites/:token/accept", h.acceptInvite)
+		api.GET("/search", h.search)
+		api.POST("/templates/:id/instantiate", h.instantiateTemplate)
+		api.GET("/trash", h.getTrash)
+		api.GET("/events", h.subscribeEvents)
+		api.GET("/activity", h.getActivityFeed)
+		api.POST("/undo/:token", h.undo)
+		api.POST("/calendar/feed", h.createCalendarFeed)
+		api.DELETE("/calendar/feed", h.deleteCalendarFeed)
+
 		items := api.Group("items")
 		{
+			items.GET("/overdue", h.getOverdueItems)
+			items.GET("/upcoming", h.getUpcomingItems)
 			items.GET("/:id", h.getItemById)
 			items.PUT("/:id", h.updateItem)
 			items.DELETE("/:id", h.deleteItem)
+			items.PUT("/:id/parent", h.setItemParent)
+			items.PATCH("/:id/position", h.moveItem)
+			items.POST("/:id/move", h.moveItemToList)
+			items.POST("/:id/copy", h.copyItemToList)
+			items.POST("/:id/archive", h.archiveItem)
+			items.DELETE("/:id/archive", h.unarchiveItem)
+			items.POST("/:id/restore", h.restoreItem)
+			items.PUT("/:id/labels/:label_id", h.attachLabel)
+			items.DELETE("/:id/labels/:label_id", h.detachLabel)
+		}
+
+		labels := api.Group("labels")
+		{
+			labels.POST("/", h.createLabel)
+			labels.GET("/", h.getAllLabels)
+			labels.DELETE("/:id", h.deleteLabel)
 		}
 	}
 
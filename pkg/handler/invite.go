@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createInviteResponse struct {
+	Invite todo.ListInvite `json:"invite"`
+	Token  string          `json:"token"`
+}
+
+// @Summary Create Invite
+// @Security ApiKeyAuth
+// @Tags invites
+// @Description create an expiring invite token for a list, owners only
+// @ID create-invite
+// @Accept  json
+// @Produce  json
+// @Param id path int true "list id"
+// @Param input body todo.CreateInviteInput true "invite info"
+// @Success 200 {object} createInviteResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/invites [post]
+func (h *Handler) createInvite(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	var input todo.CreateInviteInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	invite, token, err := h.services.ListInvite.Create(userId, listId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, createInviteResponse{
+		Invite: invite,
+		Token:  token,
+	})
+}
+
+type getAllInvitesResponse struct {
+	Data []todo.ListInvite `json:"data"`
+}
+
+// @Summary Get Invites
+// @Security ApiKeyAuth
+// @Tags invites
+// @Description get outstanding invites of a list, owners only
+// @ID get-invites
+// @Produce  json
+// @Param id path int true "list id"
+// @Success 200 {object} getAllInvitesResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/invites [get]
+func (h *Handler) getAllInvites(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	invites, err := h.services.ListInvite.GetAll(userId, listId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllInvitesResponse{
+		Data: invites,
+	})
+}
+
+// @Summary Revoke Invite
+// @Security ApiKeyAuth
+// @Tags invites
+// @Description revoke an invite, owners only
+// @ID revoke-invite
+// @Produce  json
+// @Param id path int true "list id"
+// @Param invite_id path int true "invite id"
+// @Success 200 {object} statusResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/invites/{invite_id} [delete]
+func (h *Handler) revokeInvite(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	inviteId, err := strconv.Atoi(c.Param("invite_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid invite id param")
+		return
+	}
+
+	if err := h.services.ListInvite.Revoke(userId, listId, inviteId); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Accept Invite
+// @Security ApiKeyAuth
+// @Tags invites
+// @Description join the list of an invite
+// @ID accept-invite
+// @Produce  json
+// @Param token path string true "invite token"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/invites/{token}/accept [post]
+func (h *Handler) acceptInvite(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := h.services.ListInvite.Accept(userId, c.Param("token"))
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"list_id": listId,
+	})
+}
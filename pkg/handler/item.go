@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 	"todo"
 
 	"github.com/gin-gonic/gin"
@@ -33,13 +35,13 @@ func (h *Handler) createItem(c *gin.Context) {
 	}
 
 	var input todo.TodoItem
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
 		return
 	}
 	id, err := h.services.TodoItem.Create(userId, listId, input)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, map[string]interface{}{
@@ -47,10 +49,77 @@ func (h *Handler) createItem(c *gin.Context) {
 	})
 }
 
+type bulkResponse struct {
+	Results []todo.BulkResult `json:"results"`
+	todo.UndoToken
+}
+
+// @Summary Bulk Item Operations
+// @Security ApiKeyAuth
+// @Tags items
+// @Description create, update, delete and complete items of a list in one transaction; if an operation fails none are applied and the error carries its index, otherwise the undo token reverses them all until it expires
+// @ID bulk-items
+// @Accept  json
+// @Produce  json
+// @Param id path int true "list id"
+// @Param input body todo.BulkInput true "operations"
+// @Success 200 {object} bulkResponse
+// @Failure 400 {object} errorResponse
+// @Failure 403,404,412,422 {object} bulkErrorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/items/bulk [post]
+func (h *Handler) bulkItems(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	var input todo.BulkInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	results, token, err := h.services.TodoItem.Bulk(userId, listId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, bulkResponse{Results: results, UndoToken: token})
+}
+
+// @Summary Get All Items
+// @Security ApiKeyAuth
+// @Tags items
+// @Description get items of a list, optionally filtered and sorted
+// @ID get-all-items
+// @Produce  json
+// @Param id path int true "list id"
+// @Param done query bool false "only done or only open items"
+// @Param priority query int false "priority from 0 to 3"
+// @Param label query string false "label name"
+// @Param due_before query string false "RFC 3339 timestamp"
+// @Param sort query string false "position (default), priority, due_at or created_at"
+// @Param archived query bool false "archived items instead of the others"
+// @Param view query string false "flat (default) or tree, which pages top-level items with their subtasks nested"
+// @Param limit query int false "page size"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} getAllItemsResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/items [get]
 func (h *Handler) getAllItems(c *gin.Context) {
 	userId, err := getUserId(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -60,19 +129,165 @@ func (h *Handler) getAllItems(c *gin.Context) {
 		return
 	}
 
-	items, err := h.services.TodoItem.GetAll(userId, listId)
+	filter, err := parseItemFilter(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := parsePage(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items, next, err := h.services.TodoItem.GetAll(userId, listId, filter, page)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllItemsResponse{
+		Data:       items,
+		NextCursor: next,
+	})
+}
+
+func parseItemFilter(c *gin.Context) (todo.ItemFilter, error) {
+	filter := todo.ItemFilter{Sort: c.Query("sort")}
+
+	if value, ok := c.GetQuery("done"); ok {
+		done, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("invalid done param")
+		}
+		filter.Done = &done
+	}
+
+	if value, ok := c.GetQuery("priority"); ok {
+		priority, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, errors.New("invalid priority param")
+		}
+		filter.Priority = &priority
+	}
+
+	if value, ok := c.GetQuery("label"); ok {
+		filter.Label = &value
+	}
+
+	if value, ok := c.GetQuery("due_before"); ok {
+		dueBefore, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, errors.New("invalid due_before param, expected RFC 3339")
+		}
+		filter.DueBefore = &dueBefore
+	}
+
+	if value, ok := c.GetQuery("archived"); ok {
+		archived, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, errors.New("invalid archived param")
+		}
+		filter.Archived = archived
+	}
+
+	switch c.Query("view") {
+	case "", "flat":
+	case "tree":
+		filter.Tree = true
+	default:
+		return filter, errors.New("invalid view param, expected flat or tree")
+	}
+
+	return filter, nil
+}
+
+type getAllItemsResponse struct {
+	Data       []todo.TodoItem `json:"data"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// @Summary Get Overdue Items
+// @Security ApiKeyAuth
+// @Tags items
+// @Description get unfinished items past their due date across all accessible lists
+// @ID get-overdue-items
+// @Produce  json
+// @Success 200 {object} getAllItemsResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/overdue [get]
+func (h *Handler) getOverdueItems(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	items, err := h.services.TodoItem.GetOverdue(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllItemsResponse{
+		Data: items,
+	})
+}
+
+// @Summary Get Upcoming Items
+// @Security ApiKeyAuth
+// @Tags items
+// @Description get unfinished items due within the next days across all accessible lists
+// @ID get-upcoming-items
+// @Produce  json
+// @Param days query int false "number of days to look ahead, 7 by default"
+// @Success 200 {object} getAllItemsResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/upcoming [get]
+func (h *Handler) getUpcomingItems(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, http.StatusBadRequest, "invalid days param")
 		return
 	}
 
-	c.JSON(http.StatusOK, items)
+	items, err := h.services.TodoItem.GetUpcoming(userId, days)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllItemsResponse{
+		Data: items,
+	})
 }
 
+// @Summary Get Item By Id
+// @Security ApiKeyAuth
+// @Tags items
+// @Description get item by id
+// @ID get-item-by-id
+// @Produce  json
+// @Param id path int true "item id"
+// @Param If-None-Match header string false "ETag of the cached version"
+// @Success 200 {object} todo.TodoItem
+// @Header 200 {string} ETag "version of the item and digest of the response"
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id} [get]
 func (h *Handler) getItemById(c *gin.Context) {
 	userId, err := getUserId(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -84,17 +299,35 @@ func (h *Handler) getItemById(c *gin.Context) {
 
 	item, err := h.services.TodoItem.GetById(userId, itemId)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	if notModified(c, item.Version, item) {
 		return
 	}
 
 	c.JSON(http.StatusOK, item)
 }
 
+// @Summary Update Item
+// @Security ApiKeyAuth
+// @Tags items
+// @Description update item, owners and editors only; missing or null values are left unchanged and the dates listed in clear are removed
+// @ID update-item
+// @Accept  json
+// @Produce  json
+// @Param id path int true "item id"
+// @Param If-Match header string false "ETag of the version being updated"
+// @Param input body todo.UpdateItemInput true "item info"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,412,422 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id} [put]
 func (h *Handler) updateItem(c *gin.Context) {
 	userId, err := getUserId(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -105,23 +338,203 @@ func (h *Handler) updateItem(c *gin.Context) {
 	}
 
 	var input todo.UpdateItemInput
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if input.Version, err = ifMatchVersion(c); err != nil {
+		newIfMatchErrorResponse(c, err)
 		return
 	}
 
 	if err := h.services.TodoItem.Update(userId, id, input); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, statusResponse{"ok"})
 }
 
+// @Summary Set Item Parent
+// @Security ApiKeyAuth
+// @Tags items
+// @Description move an item with its subtasks under another item of the same list, or to the top level with a null parent_id
+// @ID set-item-parent
+// @Accept  json
+// @Produce  json
+// @Param id path int true "item id"
+// @Param input body todo.SetParentInput true "new parent"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,422 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/parent [put]
+func (h *Handler) setItemParent(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.SetParentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.services.TodoItem.SetParent(userId, id, input); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Move Item
+// @Security ApiKeyAuth
+// @Tags items
+// @Description move an item right before or right after another item of its list, owners and editors only
+// @ID move-item
+// @Accept  json
+// @Produce  json
+// @Param id path int true "item id"
+// @Param input body todo.MoveInput true "the item to move before or after"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,422 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/position [patch]
+func (h *Handler) moveItem(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.MoveInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.services.TodoItem.Move(userId, id, input); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Move Item To List
+// @Security ApiKeyAuth
+// @Tags items
+// @Description move an item with its subtasks to the end of another list, requires write access to both lists
+// @ID move-item-to-list
+// @Accept  json
+// @Produce  json
+// @Param id path int true "item id"
+// @Param input body todo.TransferItemInput true "target list"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,422 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/move [post]
+func (h *Handler) moveItemToList(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.TransferItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.services.TodoItem.MoveToList(userId, id, input); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Copy Item To List
+// @Security ApiKeyAuth
+// @Tags items
+// @Description copy an item with its subtasks and labels to the end of a list, requires write access to both lists. The copies start out open.
+// @ID copy-item-to-list
+// @Accept  json
+// @Produce  json
+// @Param id path int true "item id"
+// @Param input body todo.TransferItemInput true "target list"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/copy [post]
+func (h *Handler) copyItemToList(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.TransferItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	copyId, err := h.services.TodoItem.CopyToList(userId, id, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": copyId,
+	})
+}
+
+// @Summary Delete Item
+// @Security ApiKeyAuth
+// @Tags items
+// @Description move an item and its subtasks to the trash, owners and editors only; the undo token brings them back until it expires
+// @ID delete-item
+// @Produce  json
+// @Param id path int true "item id"
+// @Param If-Match header string false "ETag of the version being deleted"
+// @Success 200 {object} undoResponse
+// @Failure 400,403,404,412 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id} [delete]
 func (h *Handler) deleteItem(c *gin.Context) {
 	userId, err := getUserId(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -131,11 +544,17 @@ func (h *Handler) deleteItem(c *gin.Context) {
 		return
 	}
 
-	err = h.services.TodoItem.Delete(userId, itemId)
+	version, err := ifMatchVersion(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newIfMatchErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, statusResponse{"ok"})
+	token, err := h.services.TodoItem.Delete(userId, itemId, version)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, undoResponse{Status: "ok", UndoToken: token})
 }
@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary JWKS
+// @Tags auth
+// @Description public keys for verifying access tokens
+// @ID jwks
+// @Produce  json
+// @Success 200 {object} service.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) getJWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, h.services.Authorization.JWKS())
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Create Label
+// @Security ApiKeyAuth
+// @Tags labels
+// @Description create a label
+// @ID create-label
+// @Accept  json
+// @Produce  json
+// @Param input body todo.Label true "label info"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/labels [post]
+func (h *Handler) createLabel(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	var input todo.Label
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	id, err := h.services.Label.Create(userId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+type getAllLabelsResponse struct {
+	Data []todo.Label `json:"data"`
+}
+
+// @Summary Get All Labels
+// @Security ApiKeyAuth
+// @Tags labels
+// @Description get labels of the user
+// @ID get-all-labels
+// @Produce  json
+// @Success 200 {object} getAllLabelsResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/labels [get]
+func (h *Handler) getAllLabels(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	labels, err := h.services.Label.GetAll(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllLabelsResponse{
+		Data: labels,
+	})
+}
+
+// @Summary Delete Label
+// @Security ApiKeyAuth
+// @Tags labels
+// @Description delete a label and detach it from all items
+// @ID delete-label
+// @Produce  json
+// @Param id path int true "label id"
+// @Success 200 {object} statusResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/labels/{id} [delete]
+func (h *Handler) deleteLabel(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.Label.Delete(userId, id); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Attach Label
+// @Security ApiKeyAuth
+// @Tags labels
+// @Description attach a label to an item
+// @ID attach-label
+// @Produce  json
+// @Param id path int true "item id"
+// @Param label_id path int true "label id"
+// @Success 200 {object} statusResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/labels/{label_id} [put]
+func (h *Handler) attachLabel(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid item id param")
+		return
+	}
+
+	labelId, err := strconv.Atoi(c.Param("label_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid label id param")
+		return
+	}
+
+	if err := h.services.Label.Attach(userId, itemId, labelId); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Detach Label
+// @Security ApiKeyAuth
+// @Tags labels
+// @Description detach a label from an item
+// @ID detach-label
+// @Produce  json
+// @Param id path int true "item id"
+// @Param label_id path int true "label id"
+// @Success 200 {object} statusResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/labels/{label_id} [delete]
+func (h *Handler) detachLabel(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	itemId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid item id param")
+		return
+	}
+
+	labelId, err := strconv.Atoi(c.Param("label_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid label id param")
+		return
+	}
+
+	if err := h.services.Label.Detach(userId, itemId, labelId); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
@@ -28,14 +28,14 @@ func (h *Handler) createList(c *gin.Context) {
 	}
 
 	var input todo.TodoList
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
 		return
 	}
 
 	id, err := h.services.TodoList.Create(userId, input)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
@@ -46,7 +46,8 @@ func (h *Handler) createList(c *gin.Context) {
 }
 
 type getAllListsResponse struct {
-	Data []todo.TodoList `json:"data"`
+	Data       []todo.TodoList `json:"data"`
+	NextCursor string          `json:"next_cursor,omitempty"`
 }
 
 // @Summary Get All Lists
@@ -56,6 +57,9 @@ type getAllListsResponse struct {
 // @ID get-all-lists
 // @Accept  json
 // @Produce  json
+// @Param archived query bool false "archived lists instead of the others"
+// @Param limit query int false "page size"
+// @Param cursor query string false "next_cursor of the previous page"
 // @Success 200 {object} getAllListsResponse
 // @Failure 400,404 {object} errorResponse
 // @Failure 500 {object} errorResponse
@@ -67,14 +71,29 @@ func (h *Handler) getAllLists(c *gin.Context) {
 		return
 	}
 
-	lists, err := h.services.TodoList.GetAll(userId)
+	page, err := parsePage(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var filter todo.ListFilter
+	if value, ok := c.GetQuery("archived"); ok {
+		if filter.Archived, err = strconv.ParseBool(value); err != nil {
+			newErrorResponse(c, http.StatusBadRequest, "invalid archived param")
+			return
+		}
+	}
+
+	lists, next, err := h.services.TodoList.GetAll(userId, filter, page)
+	if err != nil {
+		newServiceErrorResponse(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, getAllListsResponse{
-		Data: lists,
+		Data:       lists,
+		NextCursor: next,
 	})
 }
 
@@ -85,7 +104,9 @@ func (h *Handler) getAllLists(c *gin.Context) {
 // @ID get-list-by-id
 // @Accept  json
 // @Produce  json
+// @Param If-None-Match header string false "ETag of the cached version"
 // @Success 200 {object} todo.ListItem
+// @Header 200 {string} ETag "version of the list and digest of the response"
 // @Failure 400,404 {object} errorResponse
 // @Failure 500 {object} errorResponse
 // @Failure default {object} errorResponse
@@ -104,17 +125,35 @@ func (h *Handler) getListById(c *gin.Context) {
 
 	list, err := h.services.TodoList.GetById(userId, id)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	if notModified(c, list.Version, list) {
 		return
 	}
 
 	c.JSON(http.StatusOK, list)
 }
 
+// @Summary Update List
+// @Security ApiKeyAuth
+// @Tags lists
+// @Description update list, owners and editors only
+// @ID update-list
+// @Accept  json
+// @Produce  json
+// @Param id path int true "list id"
+// @Param If-Match header string false "ETag of the version being updated"
+// @Param input body todo.UpdateListInput true "list info"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404,412,422 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id} [put]
 func (h *Handler) updateList(c *gin.Context) {
 	userId, err := getUserId(c)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -125,19 +164,120 @@ func (h *Handler) updateList(c *gin.Context) {
 	}
 
 	var input todo.UpdateListInput
-	if err := c.BindJSON(&input); err != nil {
-		newErrorResponse(c, http.StatusBadRequest, err.Error())
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if input.Version, err = ifMatchVersion(c); err != nil {
+		newIfMatchErrorResponse(c, err)
 		return
 	}
 
 	if err := h.services.TodoList.Update(userId, id, input); err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Move List
+// @Security ApiKeyAuth
+// @Tags lists
+// @Description move a list right before or right after another one in the user's own order
+// @ID move-list
+// @Accept  json
+// @Produce  json
+// @Param id path int true "list id"
+// @Param input body todo.MoveInput true "the list to move before or after"
+// @Success 200 {object} statusResponse
+// @Failure 400,404,422 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/position [patch]
+func (h *Handler) moveList(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.MoveInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.services.TodoList.Move(userId, id, input); err != nil {
+		newServiceErrorResponse(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, statusResponse{"ok"})
 }
 
+// @Summary Duplicate List
+// @Security ApiKeyAuth
+// @Tags lists
+// @Description copy a list with all its items and the labels of the user on them into a new list owned by the user
+// @ID duplicate-list
+// @Accept  json
+// @Produce  json
+// @Param id path int true "list id"
+// @Param input body todo.DuplicateListInput true "copy options"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,404,422 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/duplicate [post]
+func (h *Handler) duplicateList(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.DuplicateListInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	copyId, err := h.services.TodoList.Duplicate(userId, id, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": copyId,
+	})
+}
+
+// @Summary Delete List
+// @Security ApiKeyAuth
+// @Tags lists
+// @Description move a list to the trash, owners only; the undo token brings it back until it expires
+// @ID delete-list
+// @Produce  json
+// @Param id path int true "list id"
+// @Param If-Match header string false "ETag of the version being deleted"
+// @Success 200 {object} undoResponse
+// @Failure 400,403,404,412 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id} [delete]
 func (h *Handler) deleteList(c *gin.Context) {
 	userId, err := getUserId(c)
 	if err != nil {
@@ -150,13 +290,20 @@ func (h *Handler) deleteList(c *gin.Context) {
 		return
 	}
 
-	err = h.services.TodoList.Delete(userId, id)
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		newIfMatchErrorResponse(c, err)
+		return
+	}
+
+	token, err := h.services.TodoList.Delete(userId, id, version)
 	if err != nil {
-		newErrorResponse(c, http.StatusInternalServerError, err.Error())
+		newServiceErrorResponse(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, statusResponse{
-		Status: "ok",
+	c.JSON(http.StatusOK, undoResponse{
+		Status:    "ok",
+		UndoToken: token,
 	})
 }
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+type getAllMembersResponse struct {
+	Data []todo.ListMember `json:"data"`
+}
+
+// @Summary Get List Members
+// @Security ApiKeyAuth
+// @Tags members
+// @Description get members of a list with their roles
+// @ID get-list-members
+// @Produce  json
+// @Param id path int true "list id"
+// @Success 200 {object} getAllMembersResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/members [get]
+func (h *Handler) getAllMembers(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	members, err := h.services.ListMember.GetAll(userId, listId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllMembersResponse{
+		Data: members,
+	})
+}
+
+// @Summary Share List
+// @Security ApiKeyAuth
+// @Tags members
+// @Description share a list with another user, owners only
+// @ID add-list-member
+// @Accept  json
+// @Produce  json
+// @Param id path int true "list id"
+// @Param input body todo.AddMemberInput true "member info"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/members [post]
+func (h *Handler) addMember(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	var input todo.AddMemberInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	memberId, err := h.services.ListMember.Add(userId, listId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"user_id": memberId,
+	})
+}
+
+// @Summary Change Member Role
+// @Security ApiKeyAuth
+// @Tags members
+// @Description change the role of a list member, owners only
+// @ID update-list-member
+// @Accept  json
+// @Produce  json
+// @Param id path int true "list id"
+// @Param user_id path int true "member user id"
+// @Param input body todo.UpdateMemberInput true "new role"
+// @Success 200 {object} statusResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/members/{user_id} [put]
+func (h *Handler) updateMember(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	memberId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user id param")
+		return
+	}
+
+	var input todo.UpdateMemberInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if err := h.services.ListMember.UpdateRole(userId, listId, memberId, input); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Remove Member
+// @Security ApiKeyAuth
+// @Tags members
+// @Description remove a member from a list; owners can remove anyone, members can leave
+// @ID delete-list-member
+// @Produce  json
+// @Param id path int true "list id"
+// @Param user_id path int true "member user id"
+// @Success 200 {object} statusResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/members/{user_id} [delete]
+func (h *Handler) deleteMember(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	memberId, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid user id param")
+		return
+	}
+
+	if err := h.services.ListMember.Remove(userId, listId, memberId); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
@@ -27,7 +27,8 @@ func (h *Handler) userIdentity(c *gin.Context) {
 
 	userId, err := h.services.Authorization.ParseToken(headerParts[1])
 	if err != nil {
-		newErrorResponse(c, http.StatusUnauthorized, err.Error())
+		newServiceErrorResponse(c, err)
+		return
 	}
 
 	c.Set(userCtx, userId)
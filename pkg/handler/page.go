@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parsePage reads the limit and cursor query params. A missing limit is left
+// at zero, so the service falls back to its default page size.
+func parsePage(c *gin.Context) (todo.Page, error) {
+	page := todo.Page{Cursor: c.Query("cursor")}
+
+	if value, ok := c.GetQuery("limit"); ok {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return page, errors.New("invalid limit param")
+		}
+		page.Limit = limit
+	}
+
+	return page, nil
+}
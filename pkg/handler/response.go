@@ -1,19 +1,89 @@
 package handler
 
 import (
+	"errors"
+	"net/http"
+	"todo"
+
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
 type errorResponse struct {
+	Code    string `json:"code"`
 	Message string `json:"message"`
 }
 
+// bulkErrorResponse is sent when a bulk operation fails, Index is its position
+// in the batch. None of the operations of the batch are applied.
+type bulkErrorResponse struct {
+	errorResponse
+	Index int `json:"index"`
+}
+
 type statusResponse struct {
 	Status string `json:"status"`
 }
 
+// errorCodes are the machine-readable codes sent along with error messages.
+// Clients may rely on them, so they must not change.
+var errorCodes = map[int]string{
+	http.StatusBadRequest:          "bad_request",
+	http.StatusUnauthorized:        "unauthorized",
+	http.StatusForbidden:           "forbidden",
+	http.StatusNotFound:            "not_found",
+	http.StatusConflict:            "conflict",
+	http.StatusPreconditionFailed:  "precondition_failed",
+	http.StatusUnprocessableEntity: "validation_failed",
+	http.StatusInternalServerError: "internal_error",
+}
+
+// errorStatuses maps the domain errors of the todo package to status codes.
+var errorStatuses = []struct {
+	err    error
+	status int
+}{
+	{todo.ErrNotFound, http.StatusNotFound},
+	{todo.ErrForbidden, http.StatusForbidden},
+	{todo.ErrConflict, http.StatusConflict},
+	{todo.ErrValidation, http.StatusUnprocessableEntity},
+	{todo.ErrUnauthorized, http.StatusUnauthorized},
+	{todo.ErrPrecondition, http.StatusPreconditionFailed},
+}
+
 func newErrorResponse(c *gin.Context, statusCode int, message string) {
 	logrus.Error(message)
-	c.AbortWithStatusJSON(statusCode, errorResponse{message})
+
+	code, ok := errorCodes[statusCode]
+	if !ok {
+		code = "error"
+	}
+	c.AbortWithStatusJSON(statusCode, errorResponse{Code: code, Message: message})
+}
+
+// newServiceErrorResponse responds to an error returned by the services. Errors
+// that are not domain errors are internal, their details only go to the log.
+func newServiceErrorResponse(c *gin.Context, err error) {
+	var bulkErr *todo.BulkError
+	if errors.As(err, &bulkErr) {
+		status, body := serviceError(bulkErr.Err)
+		logrus.Error(err.Error())
+		c.AbortWithStatusJSON(status, bulkErrorResponse{errorResponse: body, Index: bulkErr.Index})
+		return
+	}
+
+	status, body := serviceError(err)
+	newErrorResponse(c, status, body.Message)
+}
+
+// serviceError returns the status code and body of the response to a service error.
+func serviceError(err error) (int, errorResponse) {
+	for _, e := range errorStatuses {
+		if errors.Is(err, e.err) {
+			return e.status, errorResponse{Code: errorCodes[e.status], Message: err.Error()}
+		}
+	}
+
+	logrus.Error(err.Error())
+	return http.StatusInternalServerError, errorResponse{Code: errorCodes[http.StatusInternalServerError], Message: "internal server error"}
 }
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInvalidBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"missing fields", `{"name": "user"}`},
+		{"wrong type", `{"name": "user", "username": 1, "password": "qwerty"}`},
+		{"malformed", `{"name": `},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := testContext("", "")
+			c.Request = httptest.NewRequest(http.MethodPost, "/auth/sign-up", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			// the body is rejected before any service is called
+			(&Handler{}).signUp(c)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+			}
+			var body errorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != "validation_failed" {
+				t.Errorf("code = %q, want validation_failed", body.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+type searchResponse struct {
+	Data []todo.SearchResult `json:"data"`
+}
+
+// @Summary Search
+// @Security ApiKeyAuth
+// @Tags search
+// @Description full-text search over titles and descriptions of accessible lists and items; snippets are HTML-escaped text with the matches wrapped in <mark> tags
+// @ID search
+// @Produce  json
+// @Param q query string true "search query, web search syntax"
+// @Param limit query int false "maximum number of results"
+// @Success 200 {object} searchResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/search [get]
+func (h *Handler) search(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 {
+		newErrorResponse(c, http.StatusBadRequest, "invalid limit param")
+		return
+	}
+
+	results, err := h.services.Search.Search(userId, c.Query("q"), limit)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, searchResponse{
+		Data: results,
+	})
+}
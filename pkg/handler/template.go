@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Instantiate Template
+// @Security ApiKeyAuth
+// @Tags templates
+// @Description create a list from a template list, filling in the {{placeholders}} of titles and descriptions
+// @ID instantiate-template
+// @Accept  json
+// @Produce  json
+// @Param id path int true "template list id"
+// @Param input body todo.InstantiateTemplateInput true "title and placeholder values"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,404,422 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/templates/{id}/instantiate [post]
+func (h *Handler) instantiateTemplate(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	var input todo.InstantiateTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	listId, err := h.services.TodoList.Instantiate(userId, id, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": listId,
+	})
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Get Trash
+// @Security ApiKeyAuth
+// @Tags trash
+// @Description get the trashed lists and items the user can restore, they are purged after the retention period
+// @ID get-trash
+// @Produce  json
+// @Success 200 {object} todo.Trash
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/trash [get]
+func (h *Handler) getTrash(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	trash, err := h.services.Trash.GetAll(userId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, trash)
+}
+
+// @Summary Archive List
+// @Security ApiKeyAuth
+// @Tags lists
+// @Description hide a list from the default listing, owners and editors only
+// @ID archive-list
+// @Produce  json
+// @Param id path int true "list id"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/archive [post]
+func (h *Handler) archiveList(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.TodoList.Archive(userId, id, true); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Unarchive List
+// @Security ApiKeyAuth
+// @Tags lists
+// @Description show an archived list in the default listing again, owners and editors only
+// @ID unarchive-list
+// @Produce  json
+// @Param id path int true "list id"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/archive [delete]
+func (h *Handler) unarchiveList(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.TodoList.Archive(userId, id, false); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Restore List
+// @Security ApiKeyAuth
+// @Tags lists
+// @Description take a list out of the trash, owners only
+// @ID restore-list
+// @Produce  json
+// @Param id path int true "list id"
+// @Success 200 {object} statusResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/restore [post]
+func (h *Handler) restoreList(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.TodoList.Restore(userId, id); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Archive Item
+// @Security ApiKeyAuth
+// @Tags items
+// @Description hide an item from the default listing, owners and editors only
+// @ID archive-item
+// @Produce  json
+// @Param id path int true "item id"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/archive [post]
+func (h *Handler) archiveItem(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.TodoItem.Archive(userId, id, true); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Unarchive Item
+// @Security ApiKeyAuth
+// @Tags items
+// @Description show an archived item in the default listing again, owners and editors only
+// @ID unarchive-item
+// @Produce  json
+// @Param id path int true "item id"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/archive [delete]
+func (h *Handler) unarchiveItem(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.TodoItem.Archive(userId, id, false); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+// @Summary Restore Item
+// @Security ApiKeyAuth
+// @Tags items
+// @Description take an item out of the trash, owners and editors only
+// @ID restore-item
+// @Produce  json
+// @Param id path int true "item id"
+// @Success 200 {object} statusResponse
+// @Failure 400,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/items/{id}/restore [post]
+func (h *Handler) restoreItem(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid id param")
+		return
+	}
+
+	if err := h.services.TodoItem.Restore(userId, id); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// undoResponse answers a change that can be undone with POST /api/undo/{token}.
+type undoResponse struct {
+	Status string `json:"status"`
+	todo.UndoToken
+}
+
+// @Summary Undo Change
+// @Security ApiKeyAuth
+// @Tags undo
+// @Description reverse a delete or bulk request with the undo token it returned, before the token expires; nothing is reversed if the lists or items concerned changed since
+// @ID undo
+// @Produce  json
+// @Param token path string true "undo token"
+// @Success 200 {object} todo.UndoneChange
+// @Failure 403,404,409 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/undo/{token} [post]
+func (h *Handler) undo(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	change, err := h.services.Undo.Undo(userId, c.Param("token"))
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, change)
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"todo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary Create Webhook
+// @Security ApiKeyAuth
+// @Tags webhooks
+// @Description register a URL that changes to the items of a list are posted to, owners only. Deliveries carry X-Todo-Event, X-Todo-Delivery, X-Todo-Timestamp and X-Todo-Signature headers, the signature being sha256= followed by the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with the secret. The secret is only returned here.
+// @ID create-webhook
+// @Accept  json
+// @Produce  json
+// @Param id path int true "list id"
+// @Param input body todo.CreateWebhookInput true "webhook info"
+// @Success 200 {object} todo.Webhook
+// @Failure 400,403,404,422 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/webhooks [post]
+func (h *Handler) createWebhook(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	var input todo.CreateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		newErrorResponse(c, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	webhook, err := h.services.Webhook.Create(userId, listId, input)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+type getAllWebhooksResponse struct {
+	Data []todo.Webhook `json:"data"`
+}
+
+// @Summary Get Webhooks
+// @Security ApiKeyAuth
+// @Tags webhooks
+// @Description get the webhooks of a list, owners only
+// @ID get-webhooks
+// @Produce  json
+// @Param id path int true "list id"
+// @Success 200 {object} getAllWebhooksResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/webhooks [get]
+func (h *Handler) getAllWebhooks(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	webhooks, err := h.services.Webhook.GetAll(userId, listId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getAllWebhooksResponse{
+		Data: webhooks,
+	})
+}
+
+// @Summary Delete Webhook
+// @Security ApiKeyAuth
+// @Tags webhooks
+// @Description delete a webhook with its deliveries, owners only
+// @ID delete-webhook
+// @Produce  json
+// @Param id path int true "list id"
+// @Param webhook_id path int true "webhook id"
+// @Success 200 {object} statusResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/webhooks/{webhook_id} [delete]
+func (h *Handler) deleteWebhook(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	webhookId, err := strconv.Atoi(c.Param("webhook_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid webhook id param")
+		return
+	}
+
+	if err := h.services.Webhook.Delete(userId, listId, webhookId); err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, statusResponse{"ok"})
+}
+
+type getWebhookDeliveriesResponse struct {
+	Data       []todo.WebhookDelivery `json:"data"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+// @Summary Get Webhook Deliveries
+// @Security ApiKeyAuth
+// @Tags webhooks
+// @Description get the delivery log of a webhook, latest first, owners only
+// @ID get-webhook-deliveries
+// @Produce  json
+// @Param id path int true "list id"
+// @Param webhook_id path int true "webhook id"
+// @Param limit query int false "page size"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} getWebhookDeliveriesResponse
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/webhooks/{webhook_id}/deliveries [get]
+func (h *Handler) getWebhookDeliveries(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	webhookId, err := strconv.Atoi(c.Param("webhook_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid webhook id param")
+		return
+	}
+
+	page, err := parsePage(c)
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, next, err := h.services.Webhook.GetDeliveries(userId, listId, webhookId, page)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, getWebhookDeliveriesResponse{
+		Data:       deliveries,
+		NextCursor: next,
+	})
+}
+
+// @Summary Redeliver Webhook Delivery
+// @Security ApiKeyAuth
+// @Tags webhooks
+// @Description send the payload of a delivery again as a new delivery, owners only
+// @ID redeliver-webhook-delivery
+// @Produce  json
+// @Param id path int true "list id"
+// @Param webhook_id path int true "webhook id"
+// @Param delivery_id path int true "delivery id"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400,403,404 {object} errorResponse
+// @Failure 500 {object} errorResponse
+// @Failure default {object} errorResponse
+// @Router /api/lists/{id}/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver [post]
+func (h *Handler) redeliverWebhook(c *gin.Context) {
+	userId, err := getUserId(c)
+	if err != nil {
+		return
+	}
+
+	listId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid list id param")
+		return
+	}
+
+	webhookId, err := strconv.Atoi(c.Param("webhook_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid webhook id param")
+		return
+	}
+
+	deliveryId, err := strconv.Atoi(c.Param("delivery_id"))
+	if err != nil {
+		newErrorResponse(c, http.StatusBadRequest, "invalid delivery id param")
+		return
+	}
+
+	id, err := h.services.Webhook.Redeliver(userId, listId, webhookId, deliveryId)
+	if err != nil {
+		newServiceErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
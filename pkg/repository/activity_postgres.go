@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"todo"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// unloggedFields change along with other fields or only matter to the user
+// they belong to, so the activity log leaves them out. Labels are private to
+// the user who attached them.
+var unloggedFields = map[string]bool{
+	"id":             true,
+	"role":           true,
+	"labels":         true,
+	"position":       true,
+	"version":        true,
+	"created_at":     true,
+	"updated_at":     true,
+	"subtasks":       true,
+	"subtasks_done":  true,
+	"subtasks_total": true,
+}
+
+type ActivityPostgres struct {
+	db *sqlx.DB
+}
+
+func NewActivityPostgres(db *sqlx.DB) *ActivityPostgres {
+	return &ActivityPostgres{db: db}
+}
+
+// writeActivity logs a change to a list or an item as it was before and after, either may be nil.
+func writeActivity(tx *sqlx.Tx, userId, listId int, action, entity string, entityId int, before, after interface{}) error {
+	changes, err := diff(before, after)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (list_id, user_id, action, entity, entity_id, changes) values ($1, $2, $3, $4, $5, $6)", activityTable)
+	_, err = tx.Exec(query, listId, userId, action, entity, entityId, nullableJSON(changes))
+
+	return err
+}
+
+// itemSnapshot returns the item as the transaction sees it and locks it until the
+// transaction ends, nil if it does not exist or is in the trash.
+func itemSnapshot(tx *sqlx.Tx, itemId int) (*todo.TodoItem, error) {
+	var item todo.TodoItem
+	query := fmt.Sprintf(`SELECT %s FROM %s ti INNER JOIN %s li on li.item_id = ti.id
+									WHERE ti.id = $1 AND ti.deleted_at IS NULL FOR UPDATE OF ti`, todoItemColumns, todoItemsTable, listsItemsTable)
+	if err := tx.Get(&item, query, itemId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// listSnapshot returns the list as the transaction sees it for the user and locks
+// it until the transaction ends, nil if the user is not a member or it is in
+// the trash.
+func listSnapshot(tx *sqlx.Tx, userId, listId int) (*todo.TodoList, error) {
+	var list todo.TodoList
+	query := fmt.Sprintf(`SELECT %s FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id
+									WHERE ul.user_id = $1 AND ul.list_id = $2 AND tl.deleted_at IS NULL FOR UPDATE OF tl`,
+		todoListColumns, todoListsTable, usersListsTable)
+	if err := tx.Get(&list, query, userId, listId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// writeItemActivity logs a change to an item that was as before until then. It
+// goes to the list the item is in, or was in if it is gone.
+func writeItemActivity(tx *sqlx.Tx, userId, itemId int, action string, before *todo.TodoItem) error {
+	after, err := itemSnapshot(tx, itemId)
+	if err != nil {
+		return err
+	}
+
+	var listId int
+	switch {
+	case after != nil:
+		listId = after.ListId
+	case before != nil:
+		listId = before.ListId
+	default:
+		return nil
+	}
+
+	if action == todo.ActionItemUpdated && before != nil && after != nil && before.Done != after.Done {
+		action = todo.ActionItemReopened
+		if after.Done {
+			action = todo.ActionItemCompleted
+		}
+	}
+
+	return writeActivity(tx, userId, listId, action, todo.ActivityEntityItem, itemId, before, after)
+}
+
+// writeListActivity logs a change to a list that was as before until then.
+func writeListActivity(tx *sqlx.Tx, userId, listId int, action string, before *todo.TodoList) error {
+	after, err := listSnapshot(tx, userId, listId)
+	if err != nil {
+		return err
+	}
+
+	return writeActivity(tx, userId, listId, action, todo.ActivityEntityList, listId, before, after)
+}
+
+// GetByList returns a page of the activity of a list, latest first.
+func (r *ActivityPostgres) GetByList(listId int, page todo.Page) ([]todo.Activity, string, error) {
+	return r.getPage("a.list_id = $1", listId, page)
+}
+
+// GetFeed returns a page of the activity of every list the user is a member of, latest first.
+func (r *ActivityPostgres) GetFeed(userId int, page todo.Page) ([]todo.Activity, string, error) {
+	condition := fmt.Sprintf("a.list_id IN (SELECT list_id FROM %s WHERE user_id = $1)", usersListsTable)
+	return r.getPage(condition, userId, page)
+}
+
+func (r *ActivityPostgres) getPage(condition string, arg interface{}, page todo.Page) ([]todo.Activity, string, error) {
+	args := []interface{}{arg}
+
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor, "")
+		if err != nil {
+			return nil, "", err
+		}
+		condition += " AND a.id < $2"
+		args = append(args, c.Id)
+	}
+
+	activity := make([]todo.Activity, 0)
+	query := fmt.Sprintf(`SELECT a.id, a.list_id, a.user_id, u.username, a.action, a.entity, a.entity_id, a.changes, a.created_at
+									FROM %s a LEFT JOIN %s u on u.id = a.user_id WHERE %s ORDER BY a.id DESC LIMIT $%d`,
+		activityTable, usersTable, condition, len(args)+1)
+	args = append(args, page.Limit+1)
+	if err := r.db.Select(&activity, query, args...); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(activity) > page.Limit {
+		activity = activity[:page.Limit]
+		next = encodeCursor(cursor{Id: activity[len(activity)-1].Id})
+	}
+
+	return activity, next, nil
+}
+
+// nullableJSON passes JSON to Postgres as text, and no JSON as NULL.
+func nullableJSON(b []byte) interface{} {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
+
+// diff returns the JSON of the todo.Change values of the fields that differ
+// between before and after, nil if they are both nil or nothing changed.
+func diff(before, after interface{}) (json.RawMessage, error) {
+	from, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	to, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]todo.Change)
+	for name, value := range to {
+		if !unloggedFields[name] && !reflect.DeepEqual(from[name], value) {
+			changes[name] = todo.Change{From: from[name], To: value}
+		}
+	}
+	for name, value := range from {
+		if _, ok := to[name]; !ok && !unloggedFields[name] && value != nil {
+			changes[name] = todo.Change{From: value}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(changes)
+}
+
+// fields returns the JSON fields of an entity, nil for a nil pointer.
+func fields(entity interface{}) (map[string]interface{}, error) {
+	if entity == nil {
+		return nil, nil
+	}
+	if v := reflect.ValueOf(entity); v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	err = json.Unmarshal(b, &values)
+
+	return values, err
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+	"todo"
+)
+
+func TestDiff(t *testing.T) {
+	before := &todo.TodoItem{Id: 1, Title: "a", Priority: 1, Version: 1, Labels: []string{"x"}}
+	after := &todo.TodoItem{Id: 1, Title: "b", Priority: 1, Version: 2, Labels: []string{"y"}}
+
+	tests := []struct {
+		name   string
+		before *todo.TodoItem
+		after  *todo.TodoItem
+		want   []string
+	}{
+		{"changed", before, after, []string{"title"}},
+		{"unchanged", before, before, nil},
+		{"created", nil, after, []string{"title", "priority", "done", "description"}},
+		{"gone", before, nil, []string{"title", "priority", "done", "description"}},
+		{"none", nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if changes != nil {
+					t.Fatalf("changes = %s, want none", changes)
+				}
+				return
+			}
+
+			var got map[string]todo.Change
+			if err := json.Unmarshal(changes, &got); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.want {
+				if _, ok := got[name]; !ok {
+					t.Errorf("changes = %s, missing %s", changes, name)
+				}
+			}
+			for name := range got {
+				if unloggedFields[name] {
+					t.Errorf("changes = %s, has unlogged %s", changes, name)
+				}
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"fmt"
+	"time"
 	"todo"
 
 	"github.com/jmoiron/sqlx"
@@ -21,16 +22,74 @@ func (r *AuthPostgres) CreateUser(user todo.User) (int, error) {
 
 	row := r.db.QueryRow(query, user.Name, user.Username, user.Password)
 	if err := row.Scan(&id); err != nil {
+		if isUniqueViolation(err) {
+			return 0, todo.NewError(todo.ErrConflict, "username is already taken")
+		}
 		return 0, err
 	}
 
 	return id, nil
 }
 
-func (r *AuthPostgres) GetUser(username, password string) (todo.User, error) {
+func (r *AuthPostgres) GetUser(username string) (todo.User, error) {
 	var user todo.User
-	query := fmt.Sprintf("SELECT id FROM %s WHERE username=$1 AND password_hash=$2", usersTable)
-	err := r.db.Get(&user, query, username, password)
+	query := fmt.Sprintf("SELECT id, name, username, password_hash FROM %s WHERE username=$1", usersTable)
+	err := r.db.Get(&user, query, username)
 
-	return user, err
+	return user, notFound(err, "user not found")
+}
+
+func (r *AuthPostgres) UpdatePasswordHash(userId int, passwordHash string) error {
+	query := fmt.Sprintf("UPDATE %s SET password_hash=$1 WHERE id=$2", usersTable)
+	_, err := r.db.Exec(query, passwordHash, userId)
+
+	return err
+}
+
+func (r *AuthPostgres) CreateSession(session todo.Session) (int, error) {
+	var id int
+	query := fmt.Sprintf("INSERT INTO %s (user_id, refresh_token_hash, expires_at) values ($1, $2, $3) RETURNING id", sessionsTable)
+
+	row := r.db.QueryRow(query, session.UserId, session.RefreshTokenHash, session.ExpiresAt)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (r *AuthPostgres) GetSession(sessionId int) (todo.Session, error) {
+	var session todo.Session
+	query := fmt.Sprintf("SELECT id, user_id, refresh_token_hash, expires_at, revoked_at FROM %s WHERE id=$1", sessionsTable)
+	err := r.db.Get(&session, query, sessionId)
+
+	return session, notFound(err, "session not found")
+}
+
+func (r *AuthPostgres) GetSessionByRefreshToken(refreshTokenHash string) (todo.Session, error) {
+	var session todo.Session
+	query := fmt.Sprintf("SELECT id, user_id, refresh_token_hash, expires_at, revoked_at FROM %s WHERE refresh_token_hash=$1", sessionsTable)
+	err := r.db.Get(&session, query, refreshTokenHash)
+
+	return session, notFound(err, "session not found")
+}
+
+// RotateSession swaps the refresh token of an active session. The old hash is part
+// of the condition, so two concurrent refreshes with the same token cannot both succeed.
+func (r *AuthPostgres) RotateSession(sessionId int, oldHash, newHash string, expiresAt time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s SET refresh_token_hash=$1, expires_at=$2
+									WHERE id=$3 AND refresh_token_hash=$4 AND revoked_at IS NULL`, sessionsTable)
+	res, err := r.db.Exec(query, newHash, expiresAt, sessionId, oldHash)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res, "session is no longer active")
+}
+
+func (r *AuthPostgres) RevokeSession(sessionId int) error {
+	query := fmt.Sprintf("UPDATE %s SET revoked_at=now() WHERE id=$1 AND revoked_at IS NULL", sessionsTable)
+	_, err := r.db.Exec(query, sessionId)
+
+	return err
 }
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"fmt"
+	"todo"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type CalendarPostgres struct {
+	db *sqlx.DB
+}
+
+func NewCalendarPostgres(db *sqlx.DB) *CalendarPostgres {
+	return &CalendarPostgres{db: db}
+}
+
+// GetListItems returns the items of a list that have a due date, done or not.
+func (r *CalendarPostgres) GetListItems(userId, listId int) ([]todo.TodoItem, error) {
+	return r.getItems(userId, "li.list_id = $2", listId)
+}
+
+// GetUserItems returns the items with a due date of every list the user is a
+// member of, templates left out.
+func (r *CalendarPostgres) GetUserItems(userId int) ([]todo.TodoItem, error) {
+	return r.getItems(userId, fmt.Sprintf("li.list_id NOT IN (SELECT id FROM %s WHERE is_template)", todoListsTable))
+}
+
+// getItems returns the items with a due date of the lists of the user that
+// match conditions, in which the user is $1 and args start at $2.
+func (r *CalendarPostgres) getItems(userId int, conditions string, args ...interface{}) ([]todo.TodoItem, error) {
+	items := make([]todo.TodoItem, 0)
+	query := fmt.Sprintf(`SELECT %s FROM %s ti INNER JOIN %s li on li.item_id = ti.id
+									INNER JOIN %s ul on ul.list_id = li.list_id
+									WHERE ul.user_id = $1 AND %s AND ti.due_at IS NOT NULL AND ti.deleted_at IS NULL AND ti.archived_at IS NULL AND %s
+									ORDER BY ti.due_at, ti.id`,
+		todoItemColumns, todoItemsTable, listsItemsTable, usersListsTable, conditions, liveList("ul"))
+	if err := r.db.Select(&items, query, append([]interface{}{userId}, args...)...); err != nil {
+		return nil, err
+	}
+
+	return items, loadLabels(r.db, userId, items)
+}
+
+// SetFeed gives the user a calendar feed with the token, replacing the one
+// they had.
+func (r *CalendarPostgres) SetFeed(userId int, tokenHash string) error {
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, token_hash) VALUES ($1, $2)
+									ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = now()`, calendarFeedsTable)
+	_, err := r.db.Exec(query, userId, tokenHash)
+
+	return err
+}
+
+func (r *CalendarPostgres) DeleteFeed(userId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE user_id = $1", calendarFeedsTable)
+	res, err := r.db.Exec(query, userId)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res, "calendar feed not found")
+}
+
+// GetFeedUser returns the user whose calendar feed has the token.
+func (r *CalendarPostgres) GetFeedUser(tokenHash string) (int, error) {
+	var userId int
+	query := fmt.Sprintf("SELECT user_id FROM %s WHERE token_hash = $1", calendarFeedsTable)
+	err := r.db.Get(&userId, query, tokenHash)
+
+	return userId, notFound(err, "calendar feed not found")
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+	"todo"
+)
+
+// cursor is the position of the last row of a page, handed to clients as an
+// opaque string. Sort records which ordering it was made for, because a cursor
+// of one ordering means nothing in another.
+type cursor struct {
+	Sort      string     `json:"s,omitempty"`
+	CreatedAt time.Time  `json:"t"`
+	Id        int        `json:"i"`
+	Priority  int        `json:"p,omitempty"`
+	DueAt     *time.Time `json:"d,omitempty"`
+	Position  int64      `json:"o,omitempty"`
+}
+
+var errInvalidCursor = todo.NewError(todo.ErrValidation, "invalid cursor")
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s, sort string) (cursor, error) {
+	var c cursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+	if err := json.Unmarshal(b, &c); err != nil || c.Sort != sort {
+		return c, errInvalidCursor
+	}
+
+	return c, nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// eventsChannel is the channel app instances share events on with NOTIFY.
+const eventsChannel = "todo_events"
+
+type EventsPostgres struct {
+	db *sqlx.DB
+}
+
+func NewEventsPostgres(db *sqlx.DB) *EventsPostgres {
+	return &EventsPostgres{db: db}
+}
+
+// Recipients returns the ids of the members of a list, including lists in the trash.
+func (r *EventsPostgres) Recipients(listId int) ([]int, error) {
+	var userIds []int
+	query := fmt.Sprintf("SELECT user_id FROM %s WHERE list_id = $1", usersListsTable)
+	err := r.db.Select(&userIds, query, listId)
+
+	return userIds, err
+}
+
+// ItemList returns the list of an item, including items in the trash.
+func (r *EventsPostgres) ItemList(itemId int) (int, error) {
+	var listId int
+	query := fmt.Sprintf("SELECT list_id FROM %s WHERE item_id = $1", listsItemsTable)
+	err := r.db.Get(&listId, query, itemId)
+
+	return listId, notFound(err, "item not found")
+}
+
+// Notify sends the payload to every instance listening with EventListener,
+// this one included.
+func (r *EventsPostgres) Notify(payload []byte) error {
+	_, err := r.db.Exec("SELECT pg_notify($1, $2)", eventsChannel, string(payload))
+	return err
+}
+
+// EventListener receives the payloads sent with Notify over a connection of
+// its own, which it reestablishes when it is lost.
+type EventListener struct {
+	dsn string
+}
+
+func NewEventListener(cfg Config) *EventListener {
+	return &EventListener{dsn: cfg.dsn()}
+}
+
+// Listen calls handle with every payload until ctx is done. Payloads sent while
+// the connection is down are lost.
+func (l *EventListener) Listen(ctx context.Context, handle func(payload []byte)) error {
+	listener := pq.NewListener(l.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logrus.Errorf("error occured on events listener connection: %s", err.Error())
+		}
+	})
+	defer listener.Close()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	if err := listener.Listen(eventsChannel); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case notification := <-listener.Notify:
+			// nil means the connection was reestablished
+			if notification != nil {
+				handle([]byte(notification.Extra))
+			}
+		case <-time.After(90 * time.Second):
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+	"todo"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// listDeletion is what undoing the deletion of a list needs. The items of a
+// trashed list stay where they are, so the list only has to come out of the
+// trash with every membership it had.
+type listDeletion struct {
+	DeletedAt time.Time    `json:"deleted_at"`
+	Members   []membership `json:"members"`
+}
+
+type membership struct {
+	UserId   int    `json:"user_id" db:"user_id"`
+	Role     string `json:"role" db:"role"`
+	Position int64  `json:"position" db:"position"`
+}
+
+// itemDeletion is an item deleted along with its subtasks, which share its deleted_at.
+type itemDeletion struct {
+	ItemId    int       `json:"item_id"`
+	ItemIds   []int64   `json:"item_ids"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// itemFields are the fields of an item an update can change as they were
+// before a bulk request, with the version the request left the item at.
+type itemFields struct {
+	Id              int        `json:"id" db:"id"`
+	Title           string     `json:"title" db:"title"`
+	Description     string     `json:"description" db:"description"`
+	Done            bool       `json:"done" db:"done"`
+	CompletedAt     *time.Time `json:"completed_at" db:"completed_at"`
+	DueAt           *time.Time `json:"due_at" db:"due_at"`
+	RemindAt        *time.Time `json:"remind_at" db:"remind_at"`
+	Priority        int        `json:"priority" db:"priority"`
+	Recurrence      *string    `json:"recurrence" db:"recurrence"`
+	Timezone        *string    `json:"timezone" db:"timezone"`
+	RecurrenceStart *time.Time `json:"recurrence_start" db:"recurrence_start"`
+	Version         int        `json:"version" db:"version"`
+}
+
+// bulkChange is what undoing a bulk request needs: the items it created,
+// next occurrences included, deleted and updated.
+type bulkChange struct {
+	Created []int          `json:"created"`
+	Deleted []itemDeletion `json:"deleted"`
+	Updated []itemFields   `json:"updated"`
+}
+
+// updated records an update of the item, keeping the fields from before the
+// first update of the request.
+func (c *bulkChange) updated(tx *sqlx.Tx, itemId int) error {
+	for i := range c.Updated {
+		if c.Updated[i].Id == itemId {
+			c.Updated[i].Version++
+			return nil
+		}
+	}
+	for _, id := range c.Created {
+		if id == itemId {
+			// removing the item undoes the update as well
+			return nil
+		}
+	}
+
+	var fields itemFields
+	query := fmt.Sprintf(`SELECT id, title, description, done, completed_at, due_at, remind_at, priority, recurrence, timezone,
+										recurrence_start, version FROM %s WHERE id = $1`, todoItemsTable)
+	if err := tx.Get(&fields, query, itemId); err != nil {
+		return err
+	}
+	fields.Version++
+	c.Updated = append(c.Updated, fields)
+
+	return nil
+}
+
+type journalEntry struct {
+	Id     int    `db:"id"`
+	ListId int    `db:"list_id"`
+	Kind   string `db:"kind"`
+	State  []byte `db:"state"`
+}
+
+type JournalPostgres struct {
+	db *sqlx.DB
+}
+
+func NewJournalPostgres(db *sqlx.DB) *JournalPostgres {
+	return &JournalPostgres{db: db}
+}
+
+// writeJournal records how to reverse a change.
+func writeJournal(tx *sqlx.Tx, journal todo.Journal, userId, listId int, kind string, state interface{}) error {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (user_id, list_id, token_hash, kind, state, expires_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		changeJournalTable)
+	_, err = tx.Exec(query, userId, listId, journal.TokenHash, kind, string(payload), journal.ExpiresAt)
+
+	return err
+}
+
+// Undo reverses the change the user was given the token for, as long as it has
+// not expired or been undone already. Nothing is reversed if any of the
+// lists or items concerned changed since.
+func (r *JournalPostgres) Undo(userId int, tokenHash string) (todo.UndoneChange, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return todo.UndoneChange{}, err
+	}
+
+	change, err := undo(tx, userId, tokenHash)
+	if err != nil {
+		tx.Rollback()
+		return todo.UndoneChange{}, err
+	}
+
+	if err := writeUndoActivity(tx, userId, change); err != nil {
+		tx.Rollback()
+		return todo.UndoneChange{}, err
+	}
+
+	return change, tx.Commit()
+}
+
+// writeUndoActivity logs what undoing a change did to the list and its items.
+func writeUndoActivity(tx *sqlx.Tx, userId int, change todo.UndoneChange) error {
+	if change.Kind == todo.UndoListDelete {
+		return writeActivity(tx, userId, change.ListId, todo.ActionListRestored, todo.ActivityEntityList, change.ListId, nil, nil)
+	}
+
+	actions := []struct {
+		action  string
+		itemIds []int
+	}{
+		{todo.ActionItemRestored, change.Restored},
+		{todo.ActionItemUpdated, change.Reverted},
+		{todo.ActionItemDeleted, change.Removed},
+	}
+	for _, a := range actions {
+		for _, itemId := range a.itemIds {
+			if err := writeActivity(tx, userId, change.ListId, a.action, todo.ActivityEntityItem, itemId, nil, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func undo(tx *sqlx.Tx, userId int, tokenHash string) (todo.UndoneChange, error) {
+	var entry journalEntry
+	query := fmt.Sprintf(`SELECT id, list_id, kind, state FROM %s WHERE token_hash = $1 AND user_id = $2 AND undone_at IS NULL
+										AND expires_at > now() FOR UPDATE`, changeJournalTable)
+	if err := tx.Get(&entry, query, tokenHash, userId); err != nil {
+		return todo.UndoneChange{}, notFound(err, "undo token not found or expired")
+	}
+
+	change := todo.UndoneChange{Kind: entry.Kind, ListId: entry.ListId}
+	var err error
+	switch entry.Kind {
+	case todo.UndoListDelete:
+		err = undoListDeletion(tx, userId, entry)
+	case todo.UndoItemDelete:
+		err = undoItemDeletion(tx, userId, entry, &change)
+	case todo.UndoBulk:
+		err = undoBulk(tx, userId, entry, &change)
+	default:
+		err = fmt.Errorf("unknown change journal entry kind %q", entry.Kind)
+	}
+	if err != nil {
+		return todo.UndoneChange{}, err
+	}
+
+	undoneQuery := fmt.Sprintf("UPDATE %s SET undone_at = now() WHERE id = $1", changeJournalTable)
+	_, err = tx.Exec(undoneQuery, entry.Id)
+
+	return change, err
+}
+
+func undoListDeletion(tx *sqlx.Tx, userId int, entry journalEntry) error {
+	var state listDeletion
+	if err := json.Unmarshal(entry.State, &state); err != nil {
+		return err
+	}
+
+	// only owners can delete a list, so only they can bring it back
+	query := fmt.Sprintf(`UPDATE %s tl SET deleted_at = NULL, version = tl.version + 1 FROM %s ul
+									WHERE tl.id = ul.list_id AND ul.user_id = $1 AND tl.id = $2 AND ul.role = $3 AND tl.deleted_at = $4`,
+		todoListsTable, usersListsTable)
+	res, err := tx.Exec(query, userId, entry.ListId, todo.RoleOwner, state.DeletedAt)
+	if err != nil {
+		return err
+	}
+	if err := expectUndone(res); err != nil {
+		return err
+	}
+
+	memberQuery := fmt.Sprintf(`INSERT INTO %s (user_id, list_id, role, position) SELECT id, $2, $3, $4 FROM %s WHERE id = $1
+									ON CONFLICT (user_id, list_id) DO NOTHING`, usersListsTable, usersTable)
+	for _, member := range state.Members {
+		if _, err := tx.Exec(memberQuery, member.UserId, entry.ListId, member.Role, member.Position); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func undoItemDeletion(tx *sqlx.Tx, userId int, entry journalEntry, change *todo.UndoneChange) error {
+	var state itemDeletion
+	if err := json.Unmarshal(entry.State, &state); err != nil {
+		return err
+	}
+	if err := lockEditableList(tx, userId, entry.ListId); err != nil {
+		return err
+	}
+
+	if err := restoreItems(tx, userId, entry.ListId, state); err != nil {
+		return err
+	}
+	change.Restored = []int{state.ItemId}
+
+	return nil
+}
+
+// undoBulk reverses the operations of a bulk request. Updates are reverted
+// first, while the items the request deleted afterwards are still in the state
+// it left them in, then deleted items come back and created items go last.
+func undoBulk(tx *sqlx.Tx, userId int, entry journalEntry, change *todo.UndoneChange) error {
+	var state bulkChange
+	if err := json.Unmarshal(entry.State, &state); err != nil {
+		return err
+	}
+	if err := lockEditableList(tx, userId, entry.ListId); err != nil {
+		return err
+	}
+
+	// an item is deleted along with its parent, so an updated item may be in
+	// the trash under a deletion of another item
+	deletedAt := make(map[int]time.Time)
+	for _, deletion := range state.Deleted {
+		for _, id := range deletion.ItemIds {
+			deletedAt[int(id)] = deletion.DeletedAt
+		}
+	}
+
+	for _, fields := range state.Updated {
+		var trashedAt *time.Time
+		if t, ok := deletedAt[fields.Id]; ok {
+			trashedAt = &t
+		}
+		if err := revertItem(tx, userId, entry.ListId, fields, trashedAt); err != nil {
+			return err
+		}
+		change.Reverted = append(change.Reverted, fields.Id)
+	}
+
+	for _, deletion := range state.Deleted {
+		if err := restoreItems(tx, userId, entry.ListId, deletion); err != nil {
+			return err
+		}
+		change.Restored = append(change.Restored, deletion.ItemId)
+	}
+
+	if len(state.Created) > 0 {
+		removed, err := removeItems(tx, userId, entry.ListId, state.Created)
+		if err != nil {
+			return err
+		}
+		change.Removed = removed
+	}
+
+	return nil
+}
+
+// lockEditableList makes sure the user may still change the list and keeps
+// other changes to it out until the undo is done.
+func lockEditableList(tx *sqlx.Tx, userId, listId int) error {
+	if err := editableList(tx, userId, listId); err != nil {
+		return err
+	}
+	return lockLists(tx, listId)
+}
+
+// restoreItems takes a deleted item out of the trash with the subtasks deleted
+// along with it, as Restore does.
+func restoreItems(tx *sqlx.Tx, userId, listId int, deletion itemDeletion) error {
+	query := fmt.Sprintf(`UPDATE %s ti SET deleted_at = NULL, version = ti.version + 1,
+										parent_id = CASE WHEN ti.id = $4 AND EXISTS (SELECT 1 FROM %s p WHERE p.id = ti.parent_id AND p.deleted_at IS NOT NULL)
+											THEN NULL ELSE ti.parent_id END
+									FROM %s li WHERE li.item_id = ti.id AND li.list_id = $1 AND ti.id = ANY($2) AND ti.deleted_at = $3`,
+		todoItemsTable, todoItemsTable, listsItemsTable)
+	res, err := tx.Exec(query, listId, pq.Array(deletion.ItemIds), deletion.DeletedAt, deletion.ItemId)
+	if err != nil {
+		return err
+	}
+	if err := expectUndone(res); err != nil {
+		return err
+	}
+
+	return enqueueDeliveries(tx, todo.EventItemCreated, userId, deletion.ItemId)
+}
+
+// revertItem sets the fields of an item back unless it changed again since.
+// trashedAt is when the same bulk request deleted the item, nil if it did not.
+func revertItem(tx *sqlx.Tx, userId, listId int, fields itemFields, trashedAt *time.Time) error {
+	query := fmt.Sprintf(`UPDATE %s ti SET title = $3, description = $4, done = $5, completed_at = $6, due_at = $7, remind_at = $8,
+										priority = $9, recurrence = $10, timezone = $11, recurrence_start = $12, version = ti.version + 1
+									FROM %s li WHERE li.item_id = ti.id AND li.list_id = $1 AND ti.id = $2 AND ti.version = $13
+									AND ti.deleted_at IS NOT DISTINCT FROM $14::timestamptz`,
+		todoItemsTable, listsItemsTable)
+	res, err := tx.Exec(query, listId, fields.Id, fields.Title, fields.Description, fields.Done, fields.CompletedAt, fields.DueAt,
+		fields.RemindAt, fields.Priority, fields.Recurrence, fields.Timezone, fields.RecurrenceStart, fields.Version, trashedAt)
+	if err != nil {
+		return err
+	}
+	if err := expectUndone(res); err != nil {
+		return err
+	}
+
+	return enqueueDeliveries(tx, todo.EventItemUpdated, userId, fields.Id)
+}
+
+// removeItems moves the items that are still in the list to the trash and
+// returns which they were. Subtasks added to them since go along, as they do
+// when an item is deleted.
+func removeItems(tx *sqlx.Tx, userId, listId int, itemIds []int) ([]int, error) {
+	ids := make([]int64, len(itemIds))
+	for i, id := range itemIds {
+		ids[i] = int64(id)
+	}
+
+	var removed []int
+	query := fmt.Sprintf(`WITH RECURSIVE target AS (
+										SELECT ti.id FROM %s ti INNER JOIN %s li on li.item_id = ti.id
+										WHERE li.list_id = $1 AND ti.id = ANY($2) AND ti.deleted_at IS NULL
+									), tree AS (
+										SELECT id FROM target
+										UNION
+										SELECT s.id FROM %s s INNER JOIN tree t on s.parent_id = t.id WHERE s.deleted_at IS NULL
+									)
+									UPDATE %s SET deleted_at = now() WHERE id IN (SELECT id FROM tree) RETURNING id`,
+		todoItemsTable, listsItemsTable, todoItemsTable, todoItemsTable)
+	if err := tx.Select(&removed, query, listId, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	for _, itemId := range removed {
+		if err := enqueueDeliveries(tx, todo.EventItemDeleted, userId, itemId); err != nil {
+			return nil, err
+		}
+	}
+
+	return removed, nil
+}
+
+// expectUndone turns a reversal that matched nothing into a conflict: what it
+// would reverse has changed since.
+func expectUndone(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return todo.NewError(todo.ErrConflict, "the change cannot be undone, it has been changed since")
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"testing"
+	"time"
+	"todo"
+	"todo/pkg/testdb"
+)
+
+func TestUndoBulkUpdateThenDelete(t *testing.T) {
+	tests := []struct {
+		name string
+		// deleteParent deletes the parent of the updated item instead of the item
+		deleteParent bool
+	}{
+		{"item", false},
+		{"parent", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := testdb.Open(t)
+			items := NewTodoItemPostgres(db)
+			userId := testdb.CreateUser(t, db, "owner")
+			listId, err := NewTodoListPostgres(db).Create(userId, todo.TodoList{Title: "list"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parentId, err := items.Create(userId, listId, todo.TodoItem{Title: "parent"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			itemId, err := items.Create(userId, listId, todo.TodoItem{Title: "before", ParentId: &parentId})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deleted := itemId
+			if tt.deleteParent {
+				deleted = parentId
+			}
+			title := "after"
+			journal := todo.Journal{TokenHash: "token", ExpiresAt: time.Now().Add(time.Minute)}
+			_, err = items.Bulk(userId, listId, []todo.BulkOperation{
+				{Op: todo.BulkUpdate, Id: itemId, Update: &todo.UpdateItemInput{Title: &title}},
+				{Op: todo.BulkDelete, Id: deleted},
+			}, journal)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			change, err := NewJournalPostgres(db).Undo(userId, journal.TokenHash)
+			if err != nil {
+				t.Fatalf("undo: %v", err)
+			}
+			if len(change.Restored) != 1 || change.Restored[0] != deleted {
+				t.Errorf("restored = %v, want [%d]", change.Restored, deleted)
+			}
+			if len(change.Reverted) != 1 || change.Reverted[0] != itemId {
+				t.Errorf("reverted = %v, want [%d]", change.Reverted, itemId)
+			}
+
+			item, err := items.GetById(userId, itemId)
+			if err != nil {
+				t.Fatalf("item after undo: %v", err)
+			}
+			if item.Title != "before" {
+				t.Errorf("title = %q, want before", item.Title)
+			}
+			if item.ParentId == nil || *item.ParentId != parentId {
+				t.Errorf("parent_id = %v, want %d", item.ParentId, parentId)
+			}
+			if _, err := items.GetById(userId, parentId); err != nil {
+				t.Errorf("parent after undo: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"fmt"
+	"todo"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type LabelPostgres struct {
+	db *sqlx.DB
+}
+
+func NewLabelPostgres(db *sqlx.DB) *LabelPostgres {
+	return &LabelPostgres{db: db}
+}
+
+func (r *LabelPostgres) Create(userId int, label todo.Label) (int, error) {
+	var id int
+	query := fmt.Sprintf("INSERT INTO %s (user_id, name) VALUES ($1, $2) RETURNING id", labelsTable)
+
+	err := r.db.QueryRow(query, userId, label.Name).Scan(&id)
+	if isUniqueViolation(err) {
+		return 0, todo.NewError(todo.ErrConflict, "label already exists")
+	}
+
+	return id, err
+}
+
+func (r *LabelPostgres) GetAll(userId int) ([]todo.Label, error) {
+	labels := make([]todo.Label, 0)
+	query := fmt.Sprintf("SELECT id, name FROM %s WHERE user_id = $1 ORDER BY name", labelsTable)
+	err := r.db.Select(&labels, query, userId)
+
+	return labels, err
+}
+
+// Delete removes the label from the user's labels and from the items it is
+// attached to, which get a new version.
+func (r *LabelPostgres) Delete(userId, labelId int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	itemsQuery := fmt.Sprintf(`UPDATE %s ti SET version = ti.version + 1 FROM %s il, %s l
+									WHERE il.item_id = ti.id AND l.id = il.label_id AND l.id = $1 AND l.user_id = $2`,
+		todoItemsTable, itemsLabelsTable, labelsTable)
+	if _, err := tx.Exec(itemsQuery, labelId, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND user_id = $2", labelsTable)
+	res, err := tx.Exec(query, labelId, userId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := expectAffected(res, "label not found"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Attach labels an item the user may edit with one of the user's own labels
+// and gives the item a new version. Attaching a label twice is not an error,
+// but only the first time changes the item.
+func (r *LabelPostgres) Attach(userId, itemId, labelId int) error {
+	var found bool
+	query := fmt.Sprintf(`WITH target AS (
+										SELECT li.item_id, l.id AS label_id FROM %s ti INNER JOIN %s li on li.item_id = ti.id INNER JOIN %s ul on ul.list_id = li.list_id, %s l
+										WHERE li.item_id = $1 AND ul.user_id = $2 AND ul.role = ANY($3) AND l.id = $4 AND l.user_id = $2
+										AND ti.deleted_at IS NULL AND %s
+									), attached AS (
+										INSERT INTO %s (item_id, label_id) SELECT item_id, label_id FROM target
+										ON CONFLICT (item_id, label_id) DO NOTHING
+										RETURNING item_id
+									), bumped AS (
+										UPDATE %s SET version = version + 1 WHERE id IN (SELECT item_id FROM attached)
+									)
+									SELECT EXISTS (SELECT 1 FROM target)`,
+		todoItemsTable, listsItemsTable, usersListsTable, labelsTable, liveList("ul"), itemsLabelsTable, todoItemsTable)
+	if err := r.db.Get(&found, query, itemId, userId, pq.Array(editorRoles), labelId); err != nil {
+		return err
+	}
+	if !found {
+		return todo.NewError(todo.ErrNotFound, "item or label not found")
+	}
+
+	return nil
+}
+
+// Detach takes one of the user's own labels off an item the user may edit and
+// gives the item a new version.
+func (r *LabelPostgres) Detach(userId, itemId, labelId int) error {
+	query := fmt.Sprintf(`WITH detached AS (
+										DELETE FROM %s il USING %s ti, %s li, %s ul, %s l
+										WHERE il.item_id = ti.id AND il.item_id = li.item_id AND li.list_id = ul.list_id AND ul.user_id = $1 AND ul.role = ANY($2)
+										AND il.item_id = $3 AND il.label_id = $4 AND l.id = il.label_id AND l.user_id = $1 AND ti.deleted_at IS NULL AND %s
+										RETURNING il.item_id
+									)
+									UPDATE %s SET version = version + 1 WHERE id IN (SELECT item_id FROM detached)`,
+		itemsLabelsTable, todoItemsTable, listsItemsTable, usersListsTable, labelsTable, liveList("ul"), todoItemsTable)
+	res, err := r.db.Exec(query, userId, pq.Array(editorRoles), itemId, labelId)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res, "item or label not found")
+}
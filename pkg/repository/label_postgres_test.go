@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+	"todo"
+	"todo/pkg/testdb"
+)
+
+func TestAttachLabelTwice(t *testing.T) {
+	db := testdb.Open(t)
+	labels := NewLabelPostgres(db)
+	items := NewTodoItemPostgres(db)
+	userId := testdb.CreateUser(t, db, "user")
+	otherId := testdb.CreateUser(t, db, "other")
+
+	listId, err := NewTodoListPostgres(db).Create(userId, todo.TodoList{Title: "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemId, err := items.Create(userId, listId, todo.TodoItem{Title: "item"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	labelId, err := labels.Create(userId, todo.Label{Name: "label"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherLabelId, err := labels.Create(otherId, todo.Label{Name: "label"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := func() int {
+		t.Helper()
+		item, err := items.GetById(userId, itemId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return item.Version
+	}
+	before := version()
+
+	if err := labels.Attach(userId, itemId, labelId); err != nil {
+		t.Fatalf("attach: %v", err)
+	}
+	if got := version(); got != before+1 {
+		t.Errorf("version = %d after attaching, want %d", got, before+1)
+	}
+
+	if err := labels.Attach(userId, itemId, labelId); err != nil {
+		t.Fatalf("attach again: %v", err)
+	}
+	if got := version(); got != before+1 {
+		t.Errorf("version = %d after attaching again, want it unchanged at %d", got, before+1)
+	}
+
+	if err := labels.Attach(userId, itemId, otherLabelId); !errors.Is(err, todo.ErrNotFound) {
+		t.Errorf("err = %v, want another user's label not to be found", err)
+	}
+}
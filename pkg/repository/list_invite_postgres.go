@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"fmt"
+	"todo"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ListInvitePostgres struct {
+	db *sqlx.DB
+}
+
+func NewListInvitePostgres(db *sqlx.DB) *ListInvitePostgres {
+	return &ListInvitePostgres{db: db}
+}
+
+func (r *ListInvitePostgres) Create(userId int, invite todo.ListInvite) (int, error) {
+	var id int
+	query := fmt.Sprintf("INSERT INTO %s (list_id, created_by, role, max_uses, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING id", listInvitesTable)
+
+	row := r.db.QueryRow(query, invite.ListId, userId, invite.Role, invite.MaxUses, invite.ExpiresAt)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// GetAll returns the invites of the list that can still be accepted.
+func (r *ListInvitePostgres) GetAll(listId int) ([]todo.ListInvite, error) {
+	var invites []todo.ListInvite
+	query := fmt.Sprintf(`SELECT id, list_id, created_by, role, max_uses, uses, expires_at FROM %s
+									WHERE list_id = $1 AND revoked_at IS NULL AND expires_at > now() AND (max_uses IS NULL OR uses < max_uses)
+									ORDER BY id`, listInvitesTable)
+	err := r.db.Select(&invites, query, listId)
+
+	return invites, err
+}
+
+func (r *ListInvitePostgres) Revoke(listId, inviteId int) error {
+	query := fmt.Sprintf("UPDATE %s SET revoked_at = now() WHERE id = $1 AND list_id = $2 AND revoked_at IS NULL", listInvitesTable)
+	res, err := r.db.Exec(query, inviteId, listId)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res, "invite not found")
+}
+
+// Accept adds the user to the list of the invite and returns the list id.
+// Users who already are members keep their role and do not use up the invite.
+func (r *ListInvitePostgres) Accept(userId, inviteId int) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	var invite todo.ListInvite
+	inviteQuery := fmt.Sprintf(`SELECT id, list_id, created_by, role, max_uses, uses, expires_at FROM %s
+									WHERE id = $1 AND revoked_at IS NULL AND expires_at > now() AND (max_uses IS NULL OR uses < max_uses) AND %s
+									FOR UPDATE`, listInvitesTable, liveList(listInvitesTable))
+	if err := tx.Get(&invite, inviteQuery, inviteId); err != nil {
+		tx.Rollback()
+		return 0, notFound(err, "invite is invalid or has expired")
+	}
+
+	joinQuery := fmt.Sprintf("INSERT INTO %s (user_id, list_id, role, position) VALUES ($1, $2, $3, %s) ON CONFLICT (user_id, list_id) DO NOTHING",
+		usersListsTable, nextPosition(usersListsTable, "user_id", "$1"))
+	res, err := tx.Exec(joinQuery, userId, invite.ListId, invite.Role)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	joined, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if joined > 0 {
+		useQuery := fmt.Sprintf("UPDATE %s SET uses = uses + 1 WHERE id = $1", listInvitesTable)
+		if _, err := tx.Exec(useQuery, inviteId); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	return invite.ListId, tx.Commit()
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"todo"
+	"todo/pkg/testdb"
+)
+
+func TestAcceptInvite(t *testing.T) {
+	db := testdb.Open(t)
+	invites := NewListInvitePostgres(db)
+	members := NewListMemberPostgres(db)
+	ownerId := testdb.CreateUser(t, db, "owner")
+	listId, err := NewTodoListPostgres(db).Create(ownerId, todo.TodoList{Title: "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var users int
+	newUser := func() int {
+		users++
+		return testdb.CreateUser(t, db, fmt.Sprintf("user%d", users))
+	}
+	newInvite := func(maxUses *int, expiresAt time.Time) int {
+		t.Helper()
+		id, err := invites.Create(ownerId, todo.ListInvite{ListId: listId, Role: todo.RoleViewer, MaxUses: maxUses, ExpiresAt: expiresAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	uses := func(inviteId int) int {
+		t.Helper()
+		var uses int
+		if err := db.Get(&uses, fmt.Sprintf("SELECT uses FROM %s WHERE id = $1", listInvitesTable), inviteId); err != nil {
+			t.Fatal(err)
+		}
+		return uses
+	}
+	one := 1
+
+	t.Run("max uses", func(t *testing.T) {
+		inviteId := newInvite(&one, time.Now().Add(time.Hour))
+
+		if _, err := invites.Accept(newUser(), inviteId); err != nil {
+			t.Fatalf("first accept: %v", err)
+		}
+		if _, err := invites.Accept(newUser(), inviteId); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("err = %v, want the used up invite not to be found", err)
+		}
+		if got := uses(inviteId); got != 1 {
+			t.Errorf("uses = %d, want 1", got)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		inviteId := newInvite(nil, time.Now().Add(-time.Minute))
+
+		userId := newUser()
+		if _, err := invites.Accept(userId, inviteId); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("err = %v, want the expired invite not to be found", err)
+		}
+		if _, err := members.GetRole(userId, listId); err == nil {
+			t.Error("user joined with an expired invite")
+		}
+	})
+
+	t.Run("twice", func(t *testing.T) {
+		inviteId := newInvite(nil, time.Now().Add(time.Hour))
+
+		userId := newUser()
+		for i := 0; i < 2; i++ {
+			got, err := invites.Accept(userId, inviteId)
+			if err != nil {
+				t.Fatalf("accept %d: %v", i+1, err)
+			}
+			if got != listId {
+				t.Errorf("accept %d joined list %d, want %d", i+1, got, listId)
+			}
+		}
+		if got := uses(inviteId); got != 1 {
+			t.Errorf("uses = %d, want a member accepting again not to use up the invite", got)
+		}
+	})
+
+	t.Run("concurrently", func(t *testing.T) {
+		inviteId := newInvite(&one, time.Now().Add(time.Hour))
+
+		const accepting = 5
+		userIds := make([]int, accepting)
+		for i := range userIds {
+			userIds[i] = newUser()
+		}
+
+		errs := make([]error, accepting)
+		var wg sync.WaitGroup
+		for i, userId := range userIds {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = invites.Accept(userId, inviteId)
+			}()
+		}
+		wg.Wait()
+
+		accepted := 0
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				accepted++
+			case !errors.Is(err, todo.ErrNotFound):
+				t.Errorf("err = %v, want the used up invite not to be found", err)
+			}
+		}
+		if accepted != 1 {
+			t.Errorf("%d users joined with a single use invite", accepted)
+		}
+		if got := uses(inviteId); got != 1 {
+			t.Errorf("uses = %d, want 1", got)
+		}
+	})
+}
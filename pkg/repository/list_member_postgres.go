@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"todo"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ListMemberPostgres struct {
+	db *sqlx.DB
+}
+
+func NewListMemberPostgres(db *sqlx.DB) *ListMemberPostgres {
+	return &ListMemberPostgres{db: db}
+}
+
+func (r *ListMemberPostgres) GetAll(listId int) ([]todo.ListMember, error) {
+	var members []todo.ListMember
+	query := fmt.Sprintf(`SELECT u.id AS user_id, u.name, u.username, ul.role FROM %s ul INNER JOIN %s u on u.id = ul.user_id
+									WHERE ul.list_id = $1 ORDER BY ul.id`, usersListsTable, usersTable)
+	err := r.db.Select(&members, query, listId)
+
+	return members, err
+}
+
+func (r *ListMemberPostgres) GetRole(userId, listId int) (string, error) {
+	var role string
+	query := fmt.Sprintf("SELECT ul.role FROM %s ul WHERE ul.user_id = $1 AND ul.list_id = $2 AND %s", usersListsTable, liveList("ul"))
+	err := r.db.Get(&role, query, userId, listId)
+
+	return role, notFound(err, "list not found")
+}
+
+func (r *ListMemberPostgres) Add(listId int, username, role string) (int, error) {
+	var userId int
+	query := fmt.Sprintf(`INSERT INTO %s (user_id, list_id, role, position) SELECT u.id, $2, $3, %s FROM %s u WHERE username = $1 RETURNING user_id`,
+		usersListsTable, nextPosition(usersListsTable, "user_id", "u.id"), usersTable)
+
+	err := r.db.QueryRow(query, username, listId, role).Scan(&userId)
+	if isUniqueViolation(err) {
+		return 0, todo.NewError(todo.ErrConflict, "user is already a member of the list")
+	}
+
+	return userId, notFound(err, "user not found")
+}
+
+func (r *ListMemberPostgres) UpdateRole(listId, memberId int, role string) error {
+	return r.changeMembers(listId, func(tx *sqlx.Tx) (sql.Result, error) {
+		query := fmt.Sprintf("UPDATE %s SET role = $1 WHERE list_id = $2 AND user_id = $3", usersListsTable)
+		return tx.Exec(query, role, listId, memberId)
+	})
+}
+
+func (r *ListMemberPostgres) Remove(listId, memberId int) error {
+	return r.changeMembers(listId, func(tx *sqlx.Tx) (sql.Result, error) {
+		query := fmt.Sprintf("DELETE FROM %s WHERE list_id = $1 AND user_id = $2", usersListsTable)
+		return tx.Exec(query, listId, memberId)
+	})
+}
+
+// changeMembers applies change with the memberships of the list locked and
+// refuses to commit if the list would be left without an owner.
+func (r *ListMemberPostgres) changeMembers(listId int, change func(tx *sqlx.Tx) (sql.Result, error)) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	lockQuery := fmt.Sprintf("SELECT id FROM %s WHERE list_id = $1 FOR UPDATE", usersListsTable)
+	if _, err := tx.Exec(lockQuery, listId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	res, err := change(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := expectAffected(res, "member not found"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var owners int
+	countOwnersQuery := fmt.Sprintf("SELECT count(*) FROM %s WHERE list_id = $1 AND role = $2", usersListsTable)
+	if err := tx.Get(&owners, countOwnersQuery, listId, todo.RoleOwner); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if owners == 0 {
+		tx.Rollback()
+		return todo.NewError(todo.ErrConflict, "list must keep at least one owner")
+	}
+
+	return tx.Commit()
+}
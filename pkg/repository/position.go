@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"todo"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// positionGap is the distance between neighbouring rows after they are
+// appended or renumbered. Moving a row takes the middle of the gap it lands
+// in, so a scope only has to be renumbered once a gap is used up.
+const positionGap = 1024
+
+// nextPosition is an SQL expression for the position after the last row of
+// the scope, table being lists_items or users_lists.
+func nextPosition(table, scopeColumn, scope string) string {
+	return fmt.Sprintf("COALESCE((SELECT max(position) FROM %s WHERE %s = %s), 0) + %d", table, scopeColumn, scope, positionGap)
+}
+
+// ordering identifies the rows of lists_items or users_lists ordered together:
+// the items of a list or the lists of a user.
+type ordering struct {
+	table       string
+	scopeColumn string
+	scope       int
+	keyColumn   string
+	// outside is the message for anchors that are not part of the scope.
+	outside string
+}
+
+// move places the row of key right before or after the row of the anchor given by input.
+// The caller has to serialize moves within the scope.
+func (o ordering) move(tx *sqlx.Tx, key int, input todo.MoveInput) error {
+	anchor, before := input.Anchor()
+	if anchor == key {
+		return todo.NewError(todo.ErrValidation, "cannot move relative to itself")
+	}
+
+	position, err := o.between(tx, key, anchor, before)
+	if err != nil {
+		return err
+	}
+	if position == nil {
+		if err := o.renumber(tx); err != nil {
+			return err
+		}
+		if position, err = o.between(tx, key, anchor, before); err != nil {
+			return err
+		}
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET position = $1 WHERE %s = $2 AND %s = $3", o.table, o.scopeColumn, o.keyColumn)
+	_, err = tx.Exec(query, *position, o.scope, key)
+	return err
+}
+
+// between returns a free position next to the anchor, or nil when there is no
+// gap left on that side of it.
+func (o ordering) between(tx *sqlx.Tx, key, anchor int, before bool) (*int64, error) {
+	var anchorPosition int64
+	anchorQuery := fmt.Sprintf("SELECT position FROM %s WHERE %s = $1 AND %s = $2", o.table, o.scopeColumn, o.keyColumn)
+	if err := tx.Get(&anchorPosition, anchorQuery, o.scope, anchor); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, todo.NewError(todo.ErrValidation, o.outside)
+		}
+		return nil, err
+	}
+
+	neighbourQuery := fmt.Sprintf("SELECT min(position) FROM %s WHERE %s = $1 AND position > $2 AND %s <> $3", o.table, o.scopeColumn, o.keyColumn)
+	if before {
+		neighbourQuery = fmt.Sprintf("SELECT max(position) FROM %s WHERE %s = $1 AND position < $2 AND %s <> $3", o.table, o.scopeColumn, o.keyColumn)
+	}
+	var neighbour sql.NullInt64
+	if err := tx.Get(&neighbour, neighbourQuery, o.scope, anchorPosition, key); err != nil {
+		return nil, err
+	}
+
+	var position int64
+	switch {
+	case !neighbour.Valid && before:
+		position = anchorPosition - positionGap
+	case !neighbour.Valid:
+		position = anchorPosition + positionGap
+	default:
+		position = (anchorPosition + neighbour.Int64) / 2
+		if position == anchorPosition || position == neighbour.Int64 {
+			return nil, nil
+		}
+	}
+
+	return &position, nil
+}
+
+// renumber spreads the rows of the scope positionGap apart, keeping their order.
+func (o ordering) renumber(tx *sqlx.Tx) error {
+	query := fmt.Sprintf(`UPDATE %s t SET position = s.rn * %d FROM (
+										SELECT id, row_number() OVER (ORDER BY position, %s) AS rn FROM %s WHERE %s = $1
+									) s WHERE t.id = s.id`, o.table, positionGap, o.keyColumn, o.table, o.scopeColumn)
+	_, err := tx.Exec(query, o.scope)
+	return err
+}
@@ -1,19 +1,38 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"todo"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 const (
-	usersTable      = "users"
-	todoListsTable  = "todo_lists"
-	usersListsTable = "users_lists"
-	todoItemsTable  = "todo_items"
-	listsItemsTable = "lists_items"
+	usersTable       = "users"
+	todoListsTable   = "todo_lists"
+	usersListsTable  = "users_lists"
+	todoItemsTable   = "todo_items"
+	listsItemsTable  = "lists_items"
+	sessionsTable    = "sessions"
+	listInvitesTable = "list_invites"
+	labelsTable      = "labels"
+	itemsLabelsTable = "items_labels"
+	webhooksTable    = "webhooks"
+	activityTable    = "activity"
+
+	webhookDeliveriesTable = "webhook_deliveries"
+	changeJournalTable     = "change_journal"
+	calendarFeedsTable     = "calendar_feeds"
 )
 
+// editorRoles may modify a list and its items; everything else needs membership only,
+// except deleting a list and managing its members, which are reserved for owners.
+var editorRoles = []string{todo.RoleOwner, todo.RoleEditor}
+
 type Config struct {
 	Host     string
 	Port     string
@@ -23,8 +42,12 @@ type Config struct {
 	SSLMode  string
 }
 
+func (cfg Config) dsn() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.DBName, cfg.SSLMode)
+}
+
 func NewPostgresDB(cfg Config) (*sqlx.DB, error) {
-	db, err := sqlx.Open("postgres", fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.DBName, cfg.SSLMode))
+	db, err := sqlx.Open("postgres", cfg.dsn())
 	if err != nil {
 		return nil, err
 	}
@@ -36,3 +59,144 @@ func NewPostgresDB(cfg Config) (*sqlx.DB, error) {
 
 	return db, nil
 }
+
+const uniqueViolation = "23505"
+
+// expectAffected turns a statement that matched no rows into a not found error.
+func expectAffected(res sql.Result, message string) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return todo.NewError(todo.ErrNotFound, message)
+	}
+
+	return nil
+}
+
+// notFound turns sql.ErrNoRows into a not found error and passes other errors through.
+func notFound(err error, message string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return todo.NewError(todo.ErrNotFound, message)
+	}
+	return err
+}
+
+// listAccessError explains why a statement limited to the lists a user may change
+// matched nothing: the list is either not visible to the user at all, or the
+// user is a member without the role the change needs.
+func listAccessError(q sqlx.Queryer, userId, listId int) error {
+	if _, err := listRole(q, userId, listId); err != nil {
+		return err
+	}
+
+	return todo.NewError(todo.ErrForbidden, "insufficient permissions for this list")
+}
+
+// liveList is the condition that the list of the row alias refers to is not in
+// the trash. Everything in a trashed list is hidden along with it.
+func liveList(alias string) string {
+	return fmt.Sprintf("%s.list_id NOT IN (SELECT id FROM %s WHERE deleted_at IS NOT NULL)", alias, todoListsTable)
+}
+
+// editableList makes sure the user may change the list.
+func editableList(q sqlx.Queryer, userId, listId int) error {
+	var canEdit bool
+	query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s ul WHERE ul.user_id = $1 AND ul.list_id = $2 AND ul.role = ANY($3) AND %s)",
+		usersListsTable, liveList("ul"))
+	if err := sqlx.Get(q, &canEdit, query, userId, listId, pq.Array(editorRoles)); err != nil {
+		return err
+	}
+	if !canEdit {
+		return listAccessError(q, userId, listId)
+	}
+
+	return nil
+}
+
+// lockLists locks the lists for the rest of the transaction. Locks are taken in
+// id order, so transactions locking the same lists cannot deadlock.
+func lockLists(tx *sqlx.Tx, listIds ...int) error {
+	ids := make([]int64, len(listIds))
+	for i, id := range listIds {
+		ids[i] = int64(id)
+	}
+
+	query := fmt.Sprintf("SELECT id FROM %s WHERE id = ANY($1) ORDER BY id FOR UPDATE", todoListsTable)
+	_, err := tx.Exec(query, pq.Array(ids))
+	return err
+}
+
+// listChangeError is listAccessError for statements that also match on the
+// list version: a member with one of the allowed roles lost the race to
+// another change.
+func listChangeError(q sqlx.Queryer, userId, listId int, allowed []string, version *int) error {
+	role, err := listRole(q, userId, listId)
+	if err != nil {
+		return err
+	}
+
+	return changeError(role, allowed, version, "list")
+}
+
+// itemChangeError is listChangeError for the list an item belongs to.
+func itemChangeError(q sqlx.Queryer, userId, itemId int, allowed []string, version *int) error {
+	role, err := itemRole(q, userId, itemId)
+	if err != nil {
+		return err
+	}
+
+	return changeError(role, allowed, version, "item")
+}
+
+func changeError(role string, allowed []string, version *int, entity string) error {
+	if !slices.Contains(allowed, role) {
+		return todo.NewError(todo.ErrForbidden, "insufficient permissions for this list")
+	}
+	if version != nil {
+		return todo.NewError(todo.ErrPrecondition, entity+" has been modified")
+	}
+
+	return todo.NewError(todo.ErrNotFound, entity+" not found")
+}
+
+func listRole(q sqlx.Queryer, userId, listId int) (string, error) {
+	var role string
+	query := fmt.Sprintf("SELECT ul.role FROM %s ul WHERE ul.user_id = $1 AND ul.list_id = $2 AND %s", usersListsTable, liveList("ul"))
+	if err := sqlx.Get(q, &role, query, userId, listId); err != nil {
+		return "", notFound(err, "list not found")
+	}
+
+	return role, nil
+}
+
+func itemRole(q sqlx.Queryer, userId, itemId int) (string, error) {
+	var role string
+	query := fmt.Sprintf(`SELECT ul.role FROM %s ti INNER JOIN %s li on li.item_id = ti.id INNER JOIN %s ul on ul.list_id = li.list_id
+									WHERE ti.id = $1 AND ul.user_id = $2 AND ti.deleted_at IS NULL AND %s`,
+		todoItemsTable, listsItemsTable, usersListsTable, liveList("ul"))
+	if err := sqlx.Get(q, &role, query, itemId, userId); err != nil {
+		return "", notFound(err, "item not found")
+	}
+
+	return role, nil
+}
+
+// checkAffected returns accessError when res matched no rows.
+func checkAffected(res sql.Result, accessError func() error) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return accessError()
+	}
+
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"time"
 	"todo"
 
 	"github.com/jmoiron/sqlx"
@@ -8,29 +9,122 @@ import (
 
 type Authorization interface {
 	CreateUser(user todo.User) (int, error)
-	GetUser(username, password string) (todo.User, error)
+	GetUser(username string) (todo.User, error)
+	UpdatePasswordHash(userId int, passwordHash string) error
+	CreateSession(session todo.Session) (int, error)
+	GetSession(sessionId int) (todo.Session, error)
+	GetSessionByRefreshToken(refreshTokenHash string) (todo.Session, error)
+	RotateSession(sessionId int, oldHash, newHash string, expiresAt time.Time) error
+	RevokeSession(sessionId int) error
 }
 
 type TodoList interface {
 	Create(userId int, list todo.TodoList) (int, error)
-	GetAll(userId int) ([]todo.TodoList, error)
+	GetAll(userId int, filter todo.ListFilter, page todo.Page) ([]todo.TodoList, string, error)
 	GetById(userdId, listId int) (todo.TodoList, error)
-	Delete(userdId, listId int) error
+	Delete(userdId, listId int, version *int, journal todo.Journal) error
 	Update(userId, listId int, input todo.UpdateListInput) error
+	Move(userId, listId int, input todo.MoveInput) error
+	Clone(userId, listId int, list todo.TodoList, edit func(item *todo.TodoItem) error) (int, error)
+	Archive(userId, listId int, archived bool) error
+	Restore(userId, listId int) error
 }
 
 type TodoItem interface {
-	Create(listId int, item todo.TodoItem) (int, error)
-	GetAll(userId, listId int) ([]todo.TodoItem, error)
+	Create(userId, listId int, item todo.TodoItem) (int, error)
+	GetAll(userId, listId int, filter todo.ItemFilter, page todo.Page) ([]todo.TodoItem, string, error)
 	GetById(userId, itemId int) (todo.TodoItem, error)
-	Delete(userId, itemId int) error
+	GetDueBetween(userId int, from, to *time.Time) ([]todo.TodoItem, error)
+	Delete(userId, itemId int, version *int, journal todo.Journal) error
 	Update(userId, itemId int, input todo.UpdateItemInput) error
+	SetParent(userId, itemId int, parentId *int) error
+	Move(userId, itemId int, input todo.MoveInput) error
+	MoveToList(userId, itemId, listId int) error
+	CopyToList(userId, itemId, listId int) (int, error)
+	Archive(userId, itemId int, archived bool) error
+	Restore(userId, itemId int) error
+	Bulk(userId, listId int, ops []todo.BulkOperation, journal todo.Journal) ([]todo.BulkResult, error)
+}
+
+type ListMember interface {
+	GetAll(listId int) ([]todo.ListMember, error)
+	GetRole(userId, listId int) (string, error)
+	Add(listId int, username, role string) (int, error)
+	UpdateRole(listId, memberId int, role string) error
+	Remove(listId, memberId int) error
+}
+
+type ListInvite interface {
+	Create(userId int, invite todo.ListInvite) (int, error)
+	GetAll(listId int) ([]todo.ListInvite, error)
+	Revoke(listId, inviteId int) error
+	Accept(userId, inviteId int) (int, error)
+}
+
+type Label interface {
+	Create(userId int, label todo.Label) (int, error)
+	GetAll(userId int) ([]todo.Label, error)
+	Delete(userId, labelId int) error
+	Attach(userId, itemId, labelId int) error
+	Detach(userId, itemId, labelId int) error
+}
+
+type Search interface {
+	Search(userId int, query string, limit int) ([]todo.SearchResult, error)
+}
+
+type Trash interface {
+	GetAll(userId int) (todo.Trash, error)
+	Purge(before time.Time) (int64, error)
+}
+
+type Webhook interface {
+	Create(webhook todo.Webhook) (int, error)
+	GetAll(listId int) ([]todo.Webhook, error)
+	Delete(listId, webhookId int) error
+	GetDeliveries(listId, webhookId int, page todo.Page) ([]todo.WebhookDelivery, string, error)
+	Redeliver(listId, webhookId, deliveryId int) (int, error)
+	ClaimDue(limit int, lease time.Duration) ([]todo.WebhookDelivery, error)
+	RecordAttempt(deliveryId int, attempt todo.DeliveryAttempt) error
+}
+
+type Activity interface {
+	GetByList(listId int, page todo.Page) ([]todo.Activity, string, error)
+	GetFeed(userId int, page todo.Page) ([]todo.Activity, string, error)
+}
+
+type Calendar interface {
+	GetListItems(userId, listId int) ([]todo.TodoItem, error)
+	GetUserItems(userId int) ([]todo.TodoItem, error)
+	SetFeed(userId int, tokenHash string) error
+	DeleteFeed(userId int) error
+	GetFeedUser(tokenHash string) (int, error)
+}
+
+type Journal interface {
+	Undo(userId int, tokenHash string) (todo.UndoneChange, error)
+}
+
+type Events interface {
+	Recipients(listId int) ([]int, error)
+	ItemList(itemId int) (int, error)
+	Notify(payload []byte) error
 }
 
 type Repository struct {
 	Authorization
 	TodoList
 	TodoItem
+	ListMember
+	ListInvite
+	Label
+	Search
+	Trash
+	Events
+	Webhook
+	Activity
+	Journal
+	Calendar
 }
 
 func NewRepository(db *sqlx.DB) *Repository {
@@ -38,5 +132,15 @@ func NewRepository(db *sqlx.DB) *Repository {
 		Authorization: NewAuthPostgres(db),
 		TodoList:      NewTodoListPostgres(db),
 		TodoItem:      NewTodoItemPostgres(db),
+		ListMember:    NewListMemberPostgres(db),
+		ListInvite:    NewListInvitePostgres(db),
+		Label:         NewLabelPostgres(db),
+		Search:        NewSearchPostgres(db),
+		Trash:         NewTrashPostgres(db),
+		Events:        NewEventsPostgres(db),
+		Webhook:       NewWebhookPostgres(db),
+		Activity:      NewActivityPostgres(db),
+		Journal:       NewJournalPostgres(db),
+		Calendar:      NewCalendarPostgres(db),
 	}
 }
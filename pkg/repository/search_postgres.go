@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"todo"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// searchHeadlineOptions mark the matched words in snippets with <mark> tags.
+const searchHeadlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// htmlEscaped is the SQL expression that escapes the text of expr for HTML.
+// Snippets are built from escaped text, so the <mark> tags are the only markup
+// in them.
+func htmlEscaped(expr string) string {
+	for _, r := range [][2]string{{"&", "&amp;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&quot;"}, {"'", "&#39;"}} {
+		expr = fmt.Sprintf("replace(%s, '%s', '%s')", expr, strings.ReplaceAll(r[0], "'", "''"), r[1])
+	}
+	return expr
+}
+
+type SearchPostgres struct {
+	db *sqlx.DB
+}
+
+func NewSearchPostgres(db *sqlx.DB) *SearchPostgres {
+	return &SearchPostgres{db: db}
+}
+
+// Search matches the query against titles and descriptions of the lists the user
+// is a member of and of the items in them, best matches first.
+func (r *SearchPostgres) Search(userId int, query string, limit int) ([]todo.SearchResult, error) {
+	results := make([]todo.SearchResult, 0)
+	searchQuery := fmt.Sprintf(`WITH q AS (SELECT websearch_to_tsquery('simple', $1) AS query)
+									SELECT $4::text AS type, tl.id, tl.id AS list_id, tl.title,
+										ts_headline('simple', %s, q.query, $6) AS snippet,
+										ts_rank(tl.search_vector, q.query) AS rank
+									FROM %s tl INNER JOIN %s ul on ul.list_id = tl.id, q
+									WHERE ul.user_id = $2 AND tl.search_vector @@ q.query AND tl.deleted_at IS NULL
+									UNION ALL
+									SELECT $5::text AS type, ti.id, li.list_id, ti.title,
+										ts_headline('simple', %s, q.query, $6) AS snippet,
+										ts_rank(ti.search_vector, q.query) AS rank
+									FROM %s ti INNER JOIN %s li on li.item_id = ti.id INNER JOIN %s ul on ul.list_id = li.list_id, q
+									WHERE ul.user_id = $2 AND ti.search_vector @@ q.query AND ti.deleted_at IS NULL AND %s
+									ORDER BY rank DESC, type, id
+									LIMIT $3`,
+		htmlEscaped("tl.title || ' ' || coalesce(tl.description, '')"), todoListsTable, usersListsTable,
+		htmlEscaped("ti.title || ' ' || coalesce(ti.description, '')"), todoItemsTable, listsItemsTable, usersListsTable, liveList("ul"))
+	err := r.db.Select(&results, searchQuery, query, userId, limit, todo.SearchResultList, todo.SearchResultItem, searchHeadlineOptions)
+
+	return results, err
+}
@@ -1,13 +1,32 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 	"todo"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+const todoItemColumns = "ti.id, li.list_id, ti.parent_id, ti.title, ti.description, ti.done, ti.due_at, ti.remind_at, ti.priority, li.position, " +
+	"ti.recurrence, ti.timezone, ti.recurrence_start, ti.version, ti.created_at, ti.updated_at, ti.completed_at, ti.archived_at, ti.deleted_at, " +
+	"(SELECT count(*) FILTER (WHERE s.done) FROM " + todoItemsTable + " s WHERE s.parent_id = ti.id AND s.deleted_at IS NULL) AS subtasks_done, " +
+	"(SELECT count(*) FROM " + todoItemsTable + " s WHERE s.parent_id = ti.id AND s.deleted_at IS NULL) AS subtasks_total"
+
+// itemSortOrders maps the sort values accepted from clients to ORDER BY clauses,
+// so user input never ends up in the query text.
+var itemSortOrders = map[string]string{
+	"":                     "li.position, ti.id",
+	todo.ItemSortPosition:  "li.position, ti.id",
+	todo.ItemSortCreatedAt: "ti.created_at, ti.id",
+	todo.ItemSortPriority:  "ti.priority DESC, ti.created_at, ti.id",
+	todo.ItemSortDueAt:     "ti.due_at NULLS LAST, ti.created_at, ti.id",
+}
+
 type TodoItemPostgres struct {
 	db *sqlx.DB
 }
@@ -16,25 +35,36 @@ func NewTodoItemPostgres(db *sqlx.DB) *TodoItemPostgres {
 	return &TodoItemPostgres{db: db}
 }
 
-func (r *TodoItemPostgres) Create(listId int, item todo.TodoItem) (int, error) {
-	tx, err := r.db.Begin()
+func (r *TodoItemPostgres) Create(userId, listId int, item todo.TodoItem) (int, error) {
+	tx, err := r.db.Beginx()
 	if err != nil {
 		return 0, err
 	}
 
-	var itemId int
-	createItemQuery := fmt.Sprintf("INSERT INTO %s (title, description) values ($1, $2) RETURNING id", todoItemsTable)
+	if err := editableList(tx, userId, listId); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if item.ParentId != nil {
+		if err := checkParent(tx, listId, *item.ParentId); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
 
-	row := tx.QueryRow(createItemQuery, item.Title, item.Description)
-	err = row.Scan(&itemId)
+	itemId, err := insertItem(tx, listId, item)
 	if err != nil {
 		tx.Rollback()
 		return 0, err
 	}
 
-	createListItemsQuery := fmt.Sprintf("INSERT INTO %s (list_id, item_id) values ($1, $2)", listsItemsTable)
-	_, err = tx.Exec(createListItemsQuery, listId, itemId)
-	if err != nil {
+	if err := writeItemActivity(tx, userId, itemId, todo.ActionItemCreated, nil); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := enqueueDeliveries(tx, todo.EventItemCreated, userId, itemId); err != nil {
 		tx.Rollback()
 		return 0, err
 	}
@@ -42,39 +72,349 @@ func (r *TodoItemPostgres) Create(listId int, item todo.TodoItem) (int, error) {
 	return itemId, tx.Commit()
 }
 
-func (r *TodoItemPostgres) GetAll(userId, listId int) ([]todo.TodoItem, error) {
-	var items []todo.TodoItem
-	query := fmt.Sprintf(`SELECT ti.id, ti.title, ti.description, ti.done FROM %s ti INNER JOIN %s li on li.item_id = ti.id
-									INNER JOIN %s ul on ul.list_id = li.list_id WHERE li.list_id = $1 AND ul.user_id = $2`,
-		todoItemsTable, listsItemsTable, usersListsTable)
-	if err := r.db.Select(&items, query, listId, userId); err != nil {
-		return nil, err
+func insertItem(tx *sqlx.Tx, listId int, item todo.TodoItem) (int, error) {
+	var itemId int
+	createItemQuery := fmt.Sprintf(`INSERT INTO %s (title, description, due_at, remind_at, priority, parent_id, recurrence, timezone, recurrence_start)
+									values ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`, todoItemsTable)
+
+	row := tx.QueryRow(createItemQuery, item.Title, item.Description, item.DueAt, item.RemindAt, item.Priority, item.ParentId,
+		item.Recurrence, item.Timezone, item.RecurrenceStart)
+	if err := row.Scan(&itemId); err != nil {
+		return 0, err
+	}
+
+	createListItemsQuery := fmt.Sprintf("INSERT INTO %s (list_id, item_id, position) values ($1, $2, %s)",
+		listsItemsTable, nextPosition(listsItemsTable, "list_id", "$1"))
+	if _, err := tx.Exec(createListItemsQuery, listId, itemId); err != nil {
+		return 0, err
+	}
+
+	return itemId, nil
+}
+
+// GetAll returns a page of the list's items in the order the filter asks for
+// and the cursor of the next page.
+func (r *TodoItemPostgres) GetAll(userId, listId int, filter todo.ItemFilter, page todo.Page) ([]todo.TodoItem, string, error) {
+	conditions := []string{"li.list_id = $1", "ul.user_id = $2", "ti.deleted_at IS NULL", liveList("ul")}
+	args := []interface{}{listId, userId}
+	argId := 3
+
+	if filter.Archived {
+		conditions = append(conditions, "ti.archived_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "ti.archived_at IS NULL")
+	}
+
+	if filter.Tree {
+		conditions = append(conditions, "ti.parent_id IS NULL")
+	}
+
+	if filter.Done != nil {
+		conditions = append(conditions, fmt.Sprintf("ti.done = $%d", argId))
+		args = append(args, *filter.Done)
+		argId++
+	}
+
+	if filter.Priority != nil {
+		conditions = append(conditions, fmt.Sprintf("ti.priority = $%d", argId))
+		args = append(args, *filter.Priority)
+		argId++
+	}
+
+	if filter.Label != nil {
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (SELECT 1 FROM %s il INNER JOIN %s l on l.id = il.label_id
+									WHERE il.item_id = ti.id AND l.user_id = $2 AND l.name = $%d)`, itemsLabelsTable, labelsTable, argId))
+		args = append(args, *filter.Label)
+		argId++
+	}
+
+	if filter.DueBefore != nil {
+		conditions = append(conditions, fmt.Sprintf("ti.due_at < $%d", argId))
+		args = append(args, *filter.DueBefore)
+		argId++
+	}
+
+	orderBy, ok := itemSortOrders[filter.Sort]
+	if !ok {
+		return nil, "", todo.NewError(todo.ErrValidation, fmt.Sprintf("unknown sort %q", filter.Sort))
+	}
+
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor, filter.Sort)
+		if err != nil {
+			return nil, "", err
+		}
+		condition, cursorArgs := itemCursorCondition(filter.Sort, c, argId)
+		conditions = append(conditions, condition)
+		args = append(args, cursorArgs...)
+		argId += len(cursorArgs)
+	}
+
+	items := make([]todo.TodoItem, 0)
+	query := fmt.Sprintf(`SELECT %s FROM %s ti INNER JOIN %s li on li.item_id = ti.id
+									INNER JOIN %s ul on ul.list_id = li.list_id WHERE %s ORDER BY %s LIMIT $%d`,
+		todoItemColumns, todoItemsTable, listsItemsTable, usersListsTable, strings.Join(conditions, " AND "), orderBy, argId)
+	args = append(args, page.Limit+1)
+	if err := r.db.Select(&items, query, args...); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(items) > page.Limit {
+		items = items[:page.Limit]
+		last := items[len(items)-1]
+		next = encodeCursor(cursor{Sort: filter.Sort, CreatedAt: last.CreatedAt, Id: last.Id, Priority: last.Priority, DueAt: last.DueAt,
+			Position: last.Position})
+	}
+
+	if err := loadLabels(r.db, userId, items); err != nil {
+		return nil, "", err
+	}
+
+	if filter.Tree {
+		return items, next, r.loadSubtasks(userId, items)
+	}
+
+	return items, next, nil
+}
+
+// itemCursorCondition selects the items that come after c in the given ordering.
+// It mirrors itemSortOrders and has to be kept in line with it.
+func itemCursorCondition(sort string, c cursor, argId int) (string, []interface{}) {
+	if sort == "" || sort == todo.ItemSortPosition {
+		return fmt.Sprintf("(li.position, ti.id) > ($%d, $%d)", argId, argId+1), []interface{}{c.Position, c.Id}
 	}
 
-	return items, nil
+	tail := fmt.Sprintf("(ti.created_at, ti.id) > ($%d, $%d)", argId, argId+1)
+	args := []interface{}{c.CreatedAt, c.Id}
+
+	switch sort {
+	case todo.ItemSortPriority:
+		condition := fmt.Sprintf("(ti.priority < $%d OR (ti.priority = $%d AND %s))", argId+2, argId+2, tail)
+		return condition, append(args, c.Priority)
+	case todo.ItemSortDueAt:
+		if c.DueAt == nil {
+			return fmt.Sprintf("(ti.due_at IS NULL AND %s)", tail), args
+		}
+		condition := fmt.Sprintf("(ti.due_at > $%d OR ti.due_at IS NULL OR (ti.due_at = $%d AND %s))", argId+2, argId+2, tail)
+		return condition, append(args, *c.DueAt)
+	default:
+		return tail, args
+	}
 }
 
 func (r *TodoItemPostgres) GetById(userId, itemId int) (todo.TodoItem, error) {
 	var item todo.TodoItem
-	query := fmt.Sprintf(`SELECT ti.id, ti.title, ti.description, ti.done FROM %s ti INNER JOIN %s li on li.item_id = ti.id
-									INNER JOIN %s ul on ul.list_id = li.list_id WHERE ti.id = $1 AND ul.user_id = $2`,
-		todoItemsTable, listsItemsTable, usersListsTable)
+	query := fmt.Sprintf(`SELECT %s FROM %s ti INNER JOIN %s li on li.item_id = ti.id
+									INNER JOIN %s ul on ul.list_id = li.list_id WHERE ti.id = $1 AND ul.user_id = $2 AND ti.deleted_at IS NULL AND %s`,
+		todoItemColumns, todoItemsTable, listsItemsTable, usersListsTable, liveList("ul"))
 	if err := r.db.Get(&item, query, itemId, userId); err != nil {
+		return item, notFound(err, "item not found")
+	}
+
+	items := []todo.TodoItem{item}
+	if err := loadLabels(r.db, userId, items); err != nil {
 		return item, err
 	}
 
-	return item, nil
+	return items[0], nil
 }
 
-func (r *TodoItemPostgres) Delete(userId, itemId int) error {
-	query := fmt.Sprintf(`DELETE FROM %s ti USING %s li, %s ul 
-									WHERE ti.id = li.item_id AND li.list_id = ul.list_id AND ul.user_id = $1 AND ti.id = $2`,
-		todoItemsTable, listsItemsTable, usersListsTable)
-	_, err := r.db.Exec(query, userId, itemId)
-	return err
+// GetDueBetween returns the unfinished items with a due date in [from, to) from
+// every list the user is a member of, the ones due first coming first.
+func (r *TodoItemPostgres) GetDueBetween(userId int, from, to *time.Time) ([]todo.TodoItem, error) {
+	items := make([]todo.TodoItem, 0)
+	query := fmt.Sprintf(`SELECT %s FROM %s ti INNER JOIN %s li on li.item_id = ti.id
+									INNER JOIN %s ul on ul.list_id = li.list_id
+									WHERE ul.user_id = $1 AND ti.done = false AND ti.due_at IS NOT NULL
+									AND ti.deleted_at IS NULL AND ti.archived_at IS NULL AND %s
+									AND ($2::timestamptz IS NULL OR ti.due_at >= $2) AND ($3::timestamptz IS NULL OR ti.due_at < $3)
+									ORDER BY ti.due_at, ti.id`,
+		todoItemColumns, todoItemsTable, listsItemsTable, usersListsTable, liveList("ul"))
+	if err := r.db.Select(&items, query, userId, from, to); err != nil {
+		return nil, err
+	}
+
+	return items, loadLabels(r.db, userId, items)
+}
+
+// loadSubtasks nests all the descendants of the items in them, in list order.
+func (r *TodoItemPostgres) loadSubtasks(userId int, items []todo.TodoItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(items))
+	for i := range items {
+		ids[i] = int64(items[i].Id)
+	}
+
+	descendants := make([]todo.TodoItem, 0)
+	query := fmt.Sprintf(`WITH RECURSIVE tree AS (
+										SELECT id FROM %s WHERE parent_id = ANY($1) AND deleted_at IS NULL
+										UNION ALL
+										SELECT s.id FROM %s s INNER JOIN tree t on s.parent_id = t.id WHERE s.deleted_at IS NULL
+									)
+									SELECT %s FROM %s ti INNER JOIN %s li on li.item_id = ti.id
+									WHERE ti.id IN (SELECT id FROM tree) ORDER BY li.position, ti.id`,
+		todoItemsTable, todoItemsTable, todoItemColumns, todoItemsTable, listsItemsTable)
+	if err := r.db.Select(&descendants, query, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	if err := loadLabels(r.db, userId, descendants); err != nil {
+		return err
+	}
+
+	children := make(map[int][]todo.TodoItem)
+	for _, d := range descendants {
+		children[*d.ParentId] = append(children[*d.ParentId], d)
+	}
+
+	var nest func(item *todo.TodoItem)
+	nest = func(item *todo.TodoItem) {
+		item.Subtasks = children[item.Id]
+		for i := range item.Subtasks {
+			nest(&item.Subtasks[i])
+		}
+	}
+	for i := range items {
+		nest(&items[i])
+	}
+
+	return nil
+}
+
+// loadLabels fills in the names of the labels the user attached to the items
+// with a single query. Labels are private, other members' labels are left out.
+func loadLabels(q sqlx.Queryer, userId int, items []todo.TodoItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(items))
+	byId := make(map[int]*todo.TodoItem, len(items))
+	for i := range items {
+		items[i].Labels = make([]string, 0)
+		ids[i] = int64(items[i].Id)
+		byId[items[i].Id] = &items[i]
+	}
+
+	var itemLabels []struct {
+		ItemId int    `db:"item_id"`
+		Name   string `db:"name"`
+	}
+	query := fmt.Sprintf(`SELECT il.item_id, l.name FROM %s il INNER JOIN %s l on l.id = il.label_id
+									WHERE il.item_id = ANY($1) AND l.user_id = $2 ORDER BY l.name`, itemsLabelsTable, labelsTable)
+	if err := sqlx.Select(q, &itemLabels, query, pq.Array(ids), userId); err != nil {
+		return err
+	}
+
+	for _, il := range itemLabels {
+		item := byId[il.ItemId]
+		item.Labels = append(item.Labels, il.Name)
+	}
+
+	return nil
+}
+
+// Delete moves the item with its subtasks to the trash and records the journal
+// entry that undoes it. A non-nil version restricts it to that version of the item.
+func (r *TodoItemPostgres) Delete(userId, itemId int, version *int, journal todo.Journal) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	deletion, err := deleteItem(tx, userId, itemId, version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var listId int
+	listQuery := fmt.Sprintf("SELECT list_id FROM %s WHERE item_id = $1", listsItemsTable)
+	if err := tx.Get(&listId, listQuery, itemId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := writeJournal(tx, journal, userId, listId, todo.UndoItemDelete, deletion); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteItem(tx *sqlx.Tx, userId, itemId int, version *int) (itemDeletion, error) {
+	before, err := itemSnapshot(tx, itemId)
+	if err != nil {
+		return itemDeletion{}, err
+	}
+
+	// the subtasks get the same deleted_at as the item, so they can be restored with it
+	query := fmt.Sprintf(`WITH RECURSIVE target AS (
+										SELECT ti.id FROM %s ti INNER JOIN %s li on li.item_id = ti.id INNER JOIN %s ul on ul.list_id = li.list_id
+										WHERE ul.user_id = $1 AND ti.id = $2 AND ul.role = ANY($3) AND ti.deleted_at IS NULL AND %s
+										AND ($4::int IS NULL OR ti.version = $4)
+									), tree AS (
+										SELECT id FROM target
+										UNION ALL
+										SELECT s.id FROM %s s INNER JOIN tree t on s.parent_id = t.id WHERE s.deleted_at IS NULL
+									)
+									UPDATE %s SET deleted_at = now() WHERE id IN (SELECT id FROM tree) RETURNING id, deleted_at`,
+		todoItemsTable, listsItemsTable, usersListsTable, liveList("ul"), todoItemsTable, todoItemsTable)
+	rows, err := tx.Queryx(query, userId, itemId, pq.Array(editorRoles), version)
+	if err != nil {
+		return itemDeletion{}, err
+	}
+	defer rows.Close()
+
+	deletion := itemDeletion{ItemId: itemId}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id, &deletion.DeletedAt); err != nil {
+			return itemDeletion{}, err
+		}
+		deletion.ItemIds = append(deletion.ItemIds, id)
+	}
+	if err := rows.Err(); err != nil {
+		return itemDeletion{}, err
+	}
+	rows.Close()
+
+	if len(deletion.ItemIds) == 0 {
+		return itemDeletion{}, itemChangeError(tx, userId, itemId, editorRoles, version)
+	}
+
+	if err := writeItemActivity(tx, userId, itemId, todo.ActionItemDeleted, before); err != nil {
+		return itemDeletion{}, err
+	}
+
+	return deletion, enqueueDeliveries(tx, todo.EventItemDeleted, userId, itemId)
 }
 
 func (r *TodoItemPostgres) Update(userId, itemId int, input todo.UpdateItemInput) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	if err := r.updateItem(tx, userId, itemId, input); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TodoItemPostgres) updateItem(tx *sqlx.Tx, userId, itemId int, input todo.UpdateItemInput) error {
+	before, err := itemSnapshot(tx, itemId)
+	if err != nil {
+		return err
+	}
+
 	setValues := make([]string, 0)
 	args := make([]interface{}, 0)
 	argId := 1
@@ -92,18 +432,630 @@ func (r *TodoItemPostgres) Update(userId, itemId int, input todo.UpdateItemInput
 	}
 
 	if input.Done != nil {
-		setValues = append(setValues, fmt.Sprintf("done=$%d", argId))
+		setValues = append(setValues, fmt.Sprintf("done=$%d", argId),
+			fmt.Sprintf("completed_at=CASE WHEN $%d THEN COALESCE(ti.completed_at, now()) END", argId))
 		args = append(args, *input.Done)
 		argId++
 	}
 
+	if input.DueAt != nil {
+		setValues = append(setValues, fmt.Sprintf("due_at=$%d", argId))
+		args = append(args, *input.DueAt)
+		argId++
+	} else if input.Clears(todo.ClearDueAt) {
+		setValues = append(setValues, "due_at=NULL")
+	}
+
+	if input.RemindAt != nil {
+		setValues = append(setValues, fmt.Sprintf("remind_at=$%d", argId))
+		args = append(args, *input.RemindAt)
+		argId++
+	} else if input.Clears(todo.ClearRemindAt) {
+		setValues = append(setValues, "remind_at=NULL")
+	}
+
+	if input.Priority != nil {
+		setValues = append(setValues, fmt.Sprintf("priority=$%d", argId))
+		args = append(args, *input.Priority)
+		argId++
+	}
+
+	if input.Recurrence != nil {
+		// a new rule starts a new series at the (possibly new) due date
+		setValues = append(setValues, fmt.Sprintf("recurrence=NULLIF($%d, '')", argId),
+			fmt.Sprintf("recurrence_start=CASE WHEN $%d = '' THEN NULL ELSE COALESCE($%d::timestamptz, ti.due_at) END", argId, argId+1))
+		args = append(args, *input.Recurrence, input.DueAt)
+		argId += 2
+	}
+
+	if input.Timezone != nil {
+		setValues = append(setValues, fmt.Sprintf("timezone=NULLIF($%d, '')", argId))
+		args = append(args, *input.Timezone)
+		argId++
+	}
+
+	setValues = append(setValues, "version=ti.version+1")
 	setQuery := strings.Join(setValues, ", ")
 
 	query := fmt.Sprintf(`UPDATE %s ti SET %s FROM %s li, %s ul
-									WHERE ti.id = li.item_id AND li.list_id = ul.list_id AND ul.user_id = $%d AND ti.id = $%d`,
-		todoItemsTable, setQuery, listsItemsTable, usersListsTable, argId, argId+1)
-	args = append(args, userId, itemId)
+									WHERE ti.id = li.item_id AND li.list_id = ul.list_id AND ul.user_id = $%d AND ti.id = $%d AND ul.role = ANY($%d)
+									AND ti.deleted_at IS NULL AND %s AND ($%d::int IS NULL OR ti.version = $%d)`,
+		todoItemsTable, setQuery, listsItemsTable, usersListsTable, argId, argId+1, argId+2, liveList("ul"), argId+3, argId+3)
+	args = append(args, userId, itemId, pq.Array(editorRoles), input.Version)
+
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	if err := checkAffected(res, func() error {
+		return itemChangeError(tx, userId, itemId, editorRoles, input.Version)
+	}); err != nil {
+		return err
+	}
 
-	_, err := r.db.Exec(query, args...)
+	if err := writeItemActivity(tx, userId, itemId, todo.ActionItemUpdated, before); err != nil {
+		return err
+	}
+
+	if err := enqueueDeliveries(tx, todo.EventItemUpdated, userId, itemId); err != nil {
+		return err
+	}
+
+	if input.Next != nil {
+		if err := r.createOccurrence(tx, itemId, input.Next); err != nil {
+			return err
+		}
+		if err := writeItemActivity(tx, userId, input.Next.Id, todo.ActionItemCreated, nil); err != nil {
+			return err
+		}
+		return enqueueDeliveries(tx, todo.EventItemCreated, userId, input.Next.Id)
+	}
+
+	return nil
+}
+
+// createOccurrence adds next to the list of the item it follows, gives it the
+// same labels and sets its id.
+func (r *TodoItemPostgres) createOccurrence(tx *sqlx.Tx, itemId int, next *todo.TodoItem) error {
+	var listId int
+	listQuery := fmt.Sprintf("SELECT list_id FROM %s WHERE item_id = $1", listsItemsTable)
+	if err := tx.Get(&listId, listQuery, itemId); err != nil {
+		return err
+	}
+
+	nextId, err := insertItem(tx, listId, *next)
+	if err != nil {
+		return err
+	}
+	next.Id = nextId
+
+	labelsQuery := fmt.Sprintf("INSERT INTO %s (item_id, label_id) SELECT $1, label_id FROM %s WHERE item_id = $2", itemsLabelsTable, itemsLabelsTable)
+	_, err = tx.Exec(labelsQuery, nextId, itemId)
 	return err
 }
+
+// Archive hides the item from GetAll, or shows it again when archived is false.
+func (r *TodoItemPostgres) Archive(userId, itemId int, archived bool) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	before, err := itemSnapshot(tx, itemId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := fmt.Sprintf(`UPDATE %s ti SET archived_at = CASE WHEN $4 THEN COALESCE(ti.archived_at, now()) END, version = ti.version + 1 FROM %s li, %s ul
+									WHERE ti.id = li.item_id AND li.list_id = ul.list_id AND ul.user_id = $1 AND ti.id = $2 AND ul.role = ANY($3)
+									AND ti.deleted_at IS NULL AND %s`,
+		todoItemsTable, listsItemsTable, usersListsTable, liveList("ul"))
+	res, err := tx.Exec(query, userId, itemId, pq.Array(editorRoles), archived)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := checkAffected(res, func() error {
+		return itemChangeError(tx, userId, itemId, editorRoles, nil)
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	action := todo.ActionItemArchived
+	if !archived {
+		action = todo.ActionItemUnarchived
+	}
+	if err := writeItemActivity(tx, userId, itemId, action, before); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := enqueueDeliveries(tx, todo.EventItemUpdated, userId, itemId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Restore takes the item out of the trash along with the subtasks trashed with
+// it. The item becomes a top-level item if its parent is still in the trash.
+func (r *TodoItemPostgres) Restore(userId, itemId int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`WITH RECURSIVE target AS (
+										SELECT ti.id, ti.deleted_at FROM %s ti INNER JOIN %s li on li.item_id = ti.id INNER JOIN %s ul on ul.list_id = li.list_id
+										WHERE ul.user_id = $1 AND ti.id = $2 AND ul.role = ANY($3) AND ti.deleted_at IS NOT NULL AND %s
+									), tree AS (
+										SELECT id FROM target
+										UNION ALL
+										SELECT s.id FROM %s s INNER JOIN tree t on s.parent_id = t.id, target WHERE s.deleted_at = target.deleted_at
+									)
+									UPDATE %s ti SET deleted_at = NULL, version = ti.version + 1,
+										parent_id = CASE WHEN ti.id = $2 AND EXISTS (SELECT 1 FROM %s p WHERE p.id = ti.parent_id AND p.deleted_at IS NOT NULL)
+											THEN NULL ELSE ti.parent_id END
+									WHERE ti.id IN (SELECT id FROM tree)`,
+		todoItemsTable, listsItemsTable, usersListsTable, liveList("ul"), todoItemsTable, todoItemsTable, todoItemsTable)
+	res, err := tx.Exec(query, userId, itemId, pq.Array(editorRoles))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := expectAffected(res, "item not found in trash"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	item, err := itemSnapshot(tx, itemId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := writeActivity(tx, userId, item.ListId, todo.ActionItemRestored, todo.ActivityEntityItem, itemId, nil, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := enqueueDeliveries(tx, todo.EventItemCreated, userId, itemId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetParent moves the item with its subtasks under parentId, or to the top
+// level of its list when parentId is nil.
+func (r *TodoItemPostgres) SetParent(userId, itemId int, parentId *int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	// Moves within a list are serialized so two of them cannot build a cycle together.
+	listId, err := r.lockList(tx, userId, itemId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if parentId != nil {
+		if err := checkParent(tx, listId, *parentId); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		var cycle bool
+		cycleQuery := fmt.Sprintf(`WITH RECURSIVE ancestors AS (
+										SELECT id, parent_id FROM %s WHERE id = $1
+										UNION ALL
+										SELECT p.id, p.parent_id FROM %s p INNER JOIN ancestors a on p.id = a.parent_id
+									)
+									SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $2)`, todoItemsTable, todoItemsTable)
+		if err := tx.Get(&cycle, cycleQuery, *parentId, itemId); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if cycle {
+			tx.Rollback()
+			return todo.NewError(todo.ErrValidation, "an item cannot be moved under itself or one of its subtasks")
+		}
+	}
+
+	before, err := itemSnapshot(tx, itemId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	updateQuery := fmt.Sprintf("UPDATE %s SET parent_id = $1, version = version + 1 WHERE id = $2", todoItemsTable)
+	if _, err := tx.Exec(updateQuery, parentId, itemId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := writeItemActivity(tx, userId, itemId, todo.ActionItemUpdated, before); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := enqueueDeliveries(tx, todo.EventItemUpdated, userId, itemId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Move reorders the items of the item's list.
+func (r *TodoItemPostgres) Move(userId, itemId int, input todo.MoveInput) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	listId, err := r.lockList(tx, userId, itemId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	items := ordering{table: listsItemsTable, scopeColumn: "list_id", scope: listId, keyColumn: "item_id",
+		outside: "before and after must refer to another item of the same list"}
+	if err := items.move(tx, itemId, input); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := writeActivity(tx, userId, listId, todo.ActionItemMoved, todo.ActivityEntityItem, itemId, nil, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := enqueueDeliveries(tx, todo.EventItemUpdated, userId, itemId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockList returns the list of an item the user may edit and locks it, so that
+// structural changes within the list happen one at a time.
+func (r *TodoItemPostgres) lockList(tx *sqlx.Tx, userId, itemId int) (int, error) {
+	listId, err := r.itemList(tx, userId, itemId)
+	if err != nil {
+		return 0, err
+	}
+
+	return listId, lockLists(tx, listId)
+}
+
+// itemList returns the list of an item the user may edit.
+func (r *TodoItemPostgres) itemList(q sqlx.Queryer, userId, itemId int) (int, error) {
+	var listId int
+	listQuery := fmt.Sprintf(`SELECT li.list_id FROM %s ti INNER JOIN %s li on li.item_id = ti.id INNER JOIN %s ul on ul.list_id = li.list_id
+									WHERE ti.id = $1 AND ul.user_id = $2 AND ul.role = ANY($3) AND ti.deleted_at IS NULL AND %s`,
+		todoItemsTable, listsItemsTable, usersListsTable, liveList("ul"))
+	if err := sqlx.Get(q, &listId, listQuery, itemId, userId, pq.Array(editorRoles)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, itemChangeError(q, userId, itemId, editorRoles, nil)
+		}
+		return 0, err
+	}
+
+	return listId, nil
+}
+
+// MoveToList moves the item with its subtasks to the end of another list. The
+// item becomes a top-level item there.
+func (r *TodoItemPostgres) MoveToList(userId, itemId, listId int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	sourceId, err := r.transferLists(tx, userId, itemId, listId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if sourceId == listId {
+		tx.Rollback()
+		return todo.NewError(todo.ErrValidation, "item is already in this list")
+	}
+
+	before, err := itemSnapshot(tx, itemId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// for webhooks the items leave one list and join the other, trashed subtasks
+	// move along unnoticed
+	var moved []int
+	movedQuery := fmt.Sprintf(`WITH RECURSIVE tree AS (
+										SELECT id, 0 AS depth FROM %s WHERE id = $1
+										UNION ALL
+										SELECT s.id, t.depth + 1 FROM %s s INNER JOIN tree t on s.parent_id = t.id WHERE s.deleted_at IS NULL
+									)
+									SELECT id FROM tree ORDER BY depth, id`, todoItemsTable, todoItemsTable)
+	if err := tx.Select(&moved, movedQuery, itemId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, id := range moved {
+		if err := enqueueDeliveries(tx, todo.EventItemDeleted, userId, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	relinkQuery := fmt.Sprintf(`WITH RECURSIVE tree AS (
+										SELECT id FROM %s WHERE id = $1
+										UNION ALL
+										SELECT s.id FROM %s s INNER JOIN tree t on s.parent_id = t.id
+									)
+									UPDATE %s li SET list_id = $2, position = last.position + s.rn * %d
+									FROM (SELECT id, row_number() OVER (ORDER BY position, item_id) AS rn FROM %s
+											WHERE item_id IN (SELECT id FROM tree)) s,
+										(SELECT COALESCE(max(position), 0) AS position FROM %s WHERE list_id = $2) last
+									WHERE li.id = s.id`,
+		todoItemsTable, todoItemsTable, listsItemsTable, positionGap, listsItemsTable, listsItemsTable)
+	if _, err := tx.Exec(relinkQuery, itemId, listId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// every item of the tree is now in another list, the item itself at its top level
+	detachQuery := fmt.Sprintf(`WITH RECURSIVE tree AS (
+										SELECT id FROM %s WHERE id = $1
+										UNION ALL
+										SELECT s.id FROM %s s INNER JOIN tree t on s.parent_id = t.id
+									)
+									UPDATE %s ti SET version = ti.version + 1, parent_id = CASE WHEN ti.id = $1 THEN NULL ELSE ti.parent_id END
+									WHERE ti.id IN (SELECT id FROM tree)`,
+		todoItemsTable, todoItemsTable, todoItemsTable)
+	if _, err := tx.Exec(detachQuery, itemId); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, id := range moved {
+		if err := enqueueDeliveries(tx, todo.EventItemCreated, userId, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	after, err := itemSnapshot(tx, itemId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	// both lists log the move
+	for _, id := range []int{sourceId, listId} {
+		if err := writeActivity(tx, userId, id, todo.ActionItemMovedToList, todo.ActivityEntityItem, itemId, before, after); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CopyToList copies the item with its subtasks and labels to the end of a list,
+// which may be the item's own list. The copies start out open.
+func (r *TodoItemPostgres) CopyToList(userId, itemId, listId int) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := r.transferLists(tx, userId, itemId, listId); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// parents come before their subtasks, so their copies exist when the subtasks are copied
+	var tree []todo.TodoItem
+	treeQuery := fmt.Sprintf(`WITH RECURSIVE tree AS (
+										SELECT id, 0 AS depth FROM %s WHERE id = $1
+										UNION ALL
+										SELECT s.id, t.depth + 1 FROM %s s INNER JOIN tree t on s.parent_id = t.id WHERE s.deleted_at IS NULL
+									)
+									SELECT %s FROM tree INNER JOIN %s ti on ti.id = tree.id INNER JOIN %s li on li.item_id = ti.id
+									ORDER BY tree.depth, li.position, ti.id`,
+		todoItemsTable, todoItemsTable, todoItemColumns, todoItemsTable, listsItemsTable)
+	if err := tx.Select(&tree, treeQuery, itemId); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	copies, err := copyItems(tx, userId, listId, tree, func(item *todo.TodoItem) error {
+		item.Done = false
+		return nil
+	})
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := writeItemActivity(tx, userId, copies[itemId], todo.ActionItemCreated, nil); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	for _, item := range tree {
+		if err := enqueueDeliveries(tx, todo.EventItemCreated, userId, copies[item.Id]); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	return copies[itemId], tx.Commit()
+}
+
+// copyItems copies the items with the user's labels to the end of a list and
+// returns the ids of the copies by the ids of the originals. Parents have to
+// come before their subtasks, items whose parent is not copied become top-level
+// items. edit may change each item before it is copied, or stop the copy with an error.
+func copyItems(tx *sqlx.Tx, userId, listId int, items []todo.TodoItem, edit func(item *todo.TodoItem) error) (map[int]int, error) {
+	copies := make(map[int]int, len(items))
+	for _, item := range items {
+		parentId := item.ParentId
+		item.ParentId = nil
+		if parentId != nil {
+			if copyParentId, ok := copies[*parentId]; ok {
+				item.ParentId = &copyParentId
+			}
+		}
+		if err := edit(&item); err != nil {
+			return nil, err
+		}
+
+		copyId, err := insertItem(tx, listId, item)
+		if err != nil {
+			return nil, err
+		}
+		copies[item.Id] = copyId
+
+		if item.Done {
+			doneQuery := fmt.Sprintf("UPDATE %s SET done = true, completed_at = $1 WHERE id = $2", todoItemsTable)
+			if _, err := tx.Exec(doneQuery, item.CompletedAt, copyId); err != nil {
+				return nil, err
+			}
+		}
+
+		// the labels of other members stay with the original
+		labelsQuery := fmt.Sprintf(`INSERT INTO %s (item_id, label_id) SELECT $1, il.label_id FROM %s il INNER JOIN %s l on l.id = il.label_id
+									WHERE il.item_id = $2 AND l.user_id = $3`, itemsLabelsTable, itemsLabelsTable, labelsTable)
+		if _, err := tx.Exec(labelsQuery, copyId, item.Id, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	return copies, nil
+}
+
+// transferLists checks that the user may edit both the list of the item and the
+// target list, locks them and returns the list of the item.
+func (r *TodoItemPostgres) transferLists(tx *sqlx.Tx, userId, itemId, listId int) (int, error) {
+	sourceId, err := r.itemList(tx, userId, itemId)
+	if err != nil {
+		return 0, err
+	}
+	if err := editableList(tx, userId, listId); err != nil {
+		return 0, err
+	}
+
+	return sourceId, lockLists(tx, sourceId, listId)
+}
+
+// checkParent makes sure parentId is an item of the list.
+func checkParent(q sqlx.Queryer, listId, parentId int) error {
+	var exists bool
+	query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s li INNER JOIN %s ti on ti.id = li.item_id
+									WHERE li.list_id = $1 AND li.item_id = $2 AND ti.deleted_at IS NULL)`, listsItemsTable, todoItemsTable)
+	if err := sqlx.Get(q, &exists, query, listId, parentId); err != nil {
+		return err
+	}
+	if !exists {
+		return todo.NewError(todo.ErrValidation, "parent item must belong to the same list")
+	}
+
+	return nil
+}
+
+// Bulk applies the operations to the items of a list in a single transaction.
+// It stops at the first operation that fails and returns a *todo.BulkError.
+func (r *TodoItemPostgres) Bulk(userId, listId int, ops []todo.BulkOperation, journal todo.Journal) ([]todo.BulkResult, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := editableList(tx, userId, listId); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := lockLists(tx, listId); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	results := make([]todo.BulkResult, 0, len(ops))
+	var change bulkChange
+	for i, op := range ops {
+		itemId, err := r.applyBulk(tx, userId, listId, op, &change)
+		if err != nil {
+			tx.Rollback()
+			return nil, &todo.BulkError{Index: i, Err: err}
+		}
+		results = append(results, todo.BulkResult{Op: op.Op, Id: itemId})
+	}
+
+	if err := writeJournal(tx, journal, userId, listId, todo.UndoBulk, change); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return results, tx.Commit()
+}
+
+// applyBulk applies a single bulk operation, completions come as updates of
+// done, and adds what it did to change.
+func (r *TodoItemPostgres) applyBulk(tx *sqlx.Tx, userId, listId int, op todo.BulkOperation, change *bulkChange) (int, error) {
+	if op.Op == todo.BulkCreate {
+		if op.Item.ParentId != nil {
+			if err := checkParent(tx, listId, *op.Item.ParentId); err != nil {
+				return 0, err
+			}
+		}
+		itemId, err := insertItem(tx, listId, *op.Item)
+		if err != nil {
+			return 0, err
+		}
+		change.Created = append(change.Created, itemId)
+		if err := writeItemActivity(tx, userId, itemId, todo.ActionItemCreated, nil); err != nil {
+			return 0, err
+		}
+		return itemId, enqueueDeliveries(tx, todo.EventItemCreated, userId, itemId)
+	}
+
+	itemListId, err := r.itemList(tx, userId, op.Id)
+	if err != nil {
+		return 0, err
+	}
+	if itemListId != listId {
+		return 0, todo.NewError(todo.ErrNotFound, "item not found in this list")
+	}
+
+	if op.Op == todo.BulkDelete {
+		deletion, err := deleteItem(tx, userId, op.Id, op.Version)
+		if err != nil {
+			return 0, err
+		}
+		change.Deleted = append(change.Deleted, deletion)
+		return op.Id, nil
+	}
+
+	if err := change.updated(tx, op.Id); err != nil {
+		return 0, err
+	}
+	if err := r.updateItem(tx, userId, op.Id, *op.Update); err != nil {
+		return 0, err
+	}
+	if op.Update.Next != nil {
+		change.Created = append(change.Created, op.Update.Next.Id)
+	}
+	return op.Id, nil
+}
@@ -0,0 +1,378 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"slices"
+	"testing"
+	"time"
+	"todo"
+	"todo/pkg/testdb"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestBulkIsAllOrNothing(t *testing.T) {
+	db := testdb.Open(t)
+	lists := NewTodoListPostgres(db)
+	items := NewTodoItemPostgres(db)
+
+	userId := testdb.CreateUser(t, db, "user")
+	ownerId := testdb.CreateUser(t, db, "owner")
+
+	listId, err := lists.Create(userId, todo.TodoList{Title: "mine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemId, err := items.Create(userId, listId, todo.TodoItem{Title: "before"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the user may only look at the items of the other list
+	otherListId, err := lists.Create(ownerId, todo.TodoList{Title: "theirs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherItemId, err := items.Create(ownerId, otherListId, todo.TodoItem{Title: "theirs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewListMemberPostgres(db).Add(otherListId, "user", todo.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := items.GetById(userId, itemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := func() string {
+		var c struct {
+			Items    int `db:"items"`
+			Journal  int `db:"journal"`
+			Activity int `db:"activity"`
+		}
+		query := fmt.Sprintf(`SELECT (SELECT count(*) FROM %s WHERE deleted_at IS NULL) AS items,
+										(SELECT count(*) FROM %s) AS journal, (SELECT count(*) FROM %s) AS activity`,
+			todoItemsTable, changeJournalTable, activityTable)
+		if err := db.Get(&c, query); err != nil {
+			t.Fatal(err)
+		}
+		return fmt.Sprintf("%d items, %d journal entries, %d activities", c.Items, c.Journal, c.Activity)
+	}
+	before := counts()
+
+	title := "after"
+	_, err = items.Bulk(userId, listId, []todo.BulkOperation{
+		{Op: todo.BulkCreate, Item: &todo.TodoItem{Title: "new"}},
+		{Op: todo.BulkUpdate, Id: itemId, Update: &todo.UpdateItemInput{Title: &title}},
+		{Op: todo.BulkDelete, Id: otherItemId},
+	}, todo.Journal{TokenHash: "bulk", ExpiresAt: time.Now().Add(time.Minute)})
+
+	var bulkErr *todo.BulkError
+	if !errors.As(err, &bulkErr) {
+		t.Fatalf("err = %v, want a bulk error", err)
+	}
+	if bulkErr.Index != 2 {
+		t.Errorf("failing operation = %d, want 2", bulkErr.Index)
+	}
+	if !errors.Is(err, todo.ErrForbidden) {
+		t.Errorf("err = %v, want forbidden", err)
+	}
+
+	if after := counts(); after != before {
+		t.Errorf("after the failed batch there are %s, want %s", after, before)
+	}
+	got, err := items.GetById(userId, itemId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != item.Title || got.Version != item.Version {
+		t.Errorf("item = %q version %d, want %q version %d", got.Title, got.Version, item.Title, item.Version)
+	}
+	if _, err := items.GetById(ownerId, otherItemId); err != nil {
+		t.Errorf("item of the other list: %v", err)
+	}
+}
+
+// itemOrder returns the items of the list in their order and their positions.
+func itemOrder(t *testing.T, db *sqlx.DB, listId int) ([]int, map[int]int64) {
+	t.Helper()
+
+	var rows []struct {
+		ItemId   int   `db:"item_id"`
+		Position int64 `db:"position"`
+	}
+	query := fmt.Sprintf("SELECT item_id, position FROM %s WHERE list_id = $1 ORDER BY position, item_id", listsItemsTable)
+	if err := db.Select(&rows, query, listId); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make([]int, len(rows))
+	positions := make(map[int]int64, len(rows))
+	for i, row := range rows {
+		order[i] = row.ItemId
+		positions[row.ItemId] = row.Position
+	}
+
+	return order, positions
+}
+
+func TestMoveItems(t *testing.T) {
+	db := testdb.Open(t)
+	items := NewTodoItemPostgres(db)
+
+	userId := testdb.CreateUser(t, db, "user")
+	listId, err := NewTodoListPostgres(db).Create(userId, todo.TodoList{Title: "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, 4)
+	for i := range ids {
+		if ids[i], err = items.Create(userId, listId, todo.TodoItem{Title: fmt.Sprintf("item %d", i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b, c, d := ids[0], ids[1], ids[2], ids[3]
+
+	move := func(itemId int, input todo.MoveInput, want ...int) {
+		t.Helper()
+
+		if err := items.Move(userId, itemId, input); err != nil {
+			t.Fatalf("moving %d: %v", itemId, err)
+		}
+		if got, _ := itemOrder(t, db, listId); !slices.Equal(got, want) {
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+
+	move(d, todo.MoveInput{Before: &a}, d, a, b, c)
+	move(d, todo.MoveInput{After: &c}, a, b, c, d)
+	move(c, todo.MoveInput{Before: &b}, a, c, b, d)
+	move(a, todo.MoveInput{After: &b}, c, b, a, d)
+	move(a, todo.MoveInput{Before: &c}, a, c, b, d)
+	move(c, todo.MoveInput{After: &d}, a, b, d, c)
+
+	if err := items.Move(userId, a, todo.MoveInput{After: &a}); !errors.Is(err, todo.ErrValidation) {
+		t.Errorf("moving next to itself: err = %v, want a validation error", err)
+	}
+
+	// moving b and d after a in turn halves the gap after a each time, until
+	// the list has to be renumbered
+	_, before := itemOrder(t, db, listId)
+	moved, other := b, d
+	for i := 0; i < 2*bits.Len(positionGap); i++ {
+		move(moved, todo.MoveInput{After: &a}, a, moved, other, c)
+		moved, other = other, moved
+	}
+	if _, after := itemOrder(t, db, listId); after[c] == before[c] {
+		t.Errorf("position of %d is still %d, want the list renumbered", c, after[c])
+	}
+}
+
+// itemTree creates a parent with a child that has a child of its own.
+func itemTree(t *testing.T, items *TodoItemPostgres, userId, listId int) (parentId, childId, grandchildId int) {
+	t.Helper()
+
+	var err error
+	if parentId, err = items.Create(userId, listId, todo.TodoItem{Title: "parent"}); err != nil {
+		t.Fatal(err)
+	}
+	if childId, err = items.Create(userId, listId, todo.TodoItem{Title: "child", ParentId: &parentId}); err != nil {
+		t.Fatal(err)
+	}
+	if grandchildId, err = items.Create(userId, listId, todo.TodoItem{Title: "grandchild", ParentId: &childId}); err != nil {
+		t.Fatal(err)
+	}
+
+	return parentId, childId, grandchildId
+}
+
+func TestSetParentRejectsCycles(t *testing.T) {
+	db := testdb.Open(t)
+	items := NewTodoItemPostgres(db)
+
+	userId := testdb.CreateUser(t, db, "user")
+	listId, err := NewTodoListPostgres(db).Create(userId, todo.TodoList{Title: "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentId, childId, grandchildId := itemTree(t, items, userId, listId)
+
+	tests := []struct {
+		name     string
+		parentId int
+	}{
+		{"itself", parentId},
+		{"child", childId},
+		{"grandchild", grandchildId},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := items.SetParent(userId, parentId, &tt.parentId); !errors.Is(err, todo.ErrValidation) {
+				t.Errorf("err = %v, want a validation error", err)
+			}
+		})
+	}
+
+	// moving a subtask up is fine
+	if err := items.SetParent(userId, grandchildId, &parentId); err != nil {
+		t.Errorf("moving the grandchild under the parent: %v", err)
+	}
+	if err := items.SetParent(userId, childId, nil); err != nil {
+		t.Errorf("moving the child to the top level: %v", err)
+	}
+}
+
+func TestDeleteTrashesSubtasks(t *testing.T) {
+	db := testdb.Open(t)
+	items := NewTodoItemPostgres(db)
+
+	userId := testdb.CreateUser(t, db, "user")
+	listId, err := NewTodoListPostgres(db).Create(userId, todo.TodoList{Title: "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentId, childId, grandchildId := itemTree(t, items, userId, listId)
+	siblingId, err := items.Create(userId, listId, todo.TodoItem{Title: "sibling"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	journal := todo.Journal{TokenHash: "delete", ExpiresAt: time.Now().Add(time.Minute)}
+	if err := items.Delete(userId, parentId, nil, journal); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int{parentId, childId, grandchildId} {
+		if _, err := items.GetById(userId, id); !errors.Is(err, todo.ErrNotFound) {
+			t.Errorf("item %d: err = %v, want it in the trash", id, err)
+		}
+	}
+	if _, err := items.GetById(userId, siblingId); err != nil {
+		t.Errorf("sibling: %v", err)
+	}
+
+	// the subtree is restored as a whole
+	if err := items.Restore(userId, parentId); err != nil {
+		t.Fatal(err)
+	}
+	grandchild, err := items.GetById(userId, grandchildId)
+	if err != nil {
+		t.Fatalf("grandchild after restoring: %v", err)
+	}
+	if grandchild.ParentId == nil || *grandchild.ParentId != childId {
+		t.Errorf("grandchild parent = %v, want %d", grandchild.ParentId, childId)
+	}
+}
+
+func TestMoveAndCopyToList(t *testing.T) {
+	db := testdb.Open(t)
+	lists := NewTodoListPostgres(db)
+	items := NewTodoItemPostgres(db)
+
+	userId := testdb.CreateUser(t, db, "user")
+	ownerId := testdb.CreateUser(t, db, "owner")
+
+	newList := func(userId int, title string) int {
+		t.Helper()
+		listId, err := lists.Create(userId, todo.TodoList{Title: title})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return listId
+	}
+	newItem := func(userId, listId int, title string, parentId *int) int {
+		t.Helper()
+		itemId, err := items.Create(userId, listId, todo.TodoItem{Title: title, ParentId: parentId})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return itemId
+	}
+
+	sourceId := newList(userId, "source")
+	targetId := newList(userId, "target")
+	viewedId := newList(ownerId, "viewed")
+	if _, err := NewListMemberPostgres(db).Add(viewedId, "user", todo.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+
+	parentId := newItem(userId, sourceId, "parent", nil)
+	firstId := newItem(userId, sourceId, "first", &parentId)
+	secondId := newItem(userId, sourceId, "second", &parentId)
+	// the subtasks keep their order, not the one they were created in
+	if err := items.Move(userId, secondId, todo.MoveInput{Before: &firstId}); err != nil {
+		t.Fatal(err)
+	}
+	existingId := newItem(userId, targetId, "existing", nil)
+	viewedItemId := newItem(ownerId, viewedId, "viewed", nil)
+
+	refused := []struct {
+		name     string
+		transfer func() error
+	}{
+		{"move to a viewed list", func() error { return items.MoveToList(userId, parentId, viewedId) }},
+		{"copy to a viewed list", func() error { _, err := items.CopyToList(userId, parentId, viewedId); return err }},
+		{"move from a viewed list", func() error { return items.MoveToList(userId, viewedItemId, targetId) }},
+		{"copy from a viewed list", func() error { _, err := items.CopyToList(userId, viewedItemId, targetId); return err }},
+	}
+	for _, tt := range refused {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.transfer(); !errors.Is(err, todo.ErrForbidden) {
+				t.Errorf("err = %v, want forbidden", err)
+			}
+		})
+	}
+	if order, _ := itemOrder(t, db, viewedId); !slices.Equal(order, []int{viewedItemId}) {
+		t.Errorf("viewed list has items %v, want only %d", order, viewedItemId)
+	}
+
+	get := func(itemId int) todo.TodoItem {
+		t.Helper()
+		item, err := items.GetById(userId, itemId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return item
+	}
+	parentOf := func(itemId int) int {
+		t.Helper()
+		if parentId := get(itemId).ParentId; parentId != nil {
+			return *parentId
+		}
+		return 0
+	}
+
+	copyId, err := items.CopyToList(userId, parentId, targetId)
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	copied, _ := itemOrder(t, db, targetId)
+	if len(copied) != 4 || copied[0] != existingId || copied[1] != copyId {
+		t.Fatalf("target list after the copy = %v, want %d, %d and its subtasks", copied, existingId, copyId)
+	}
+	for i, title := range []string{"second", "first"} {
+		id := copied[2+i]
+		if got := get(id); got.Title != title || parentOf(id) != copyId {
+			t.Errorf("copied subtask %d = %q under %d, want %q under %d", i, got.Title, parentOf(id), title, copyId)
+		}
+	}
+
+	if err := items.MoveToList(userId, parentId, targetId); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if order, _ := itemOrder(t, db, sourceId); len(order) != 0 {
+		t.Errorf("source list still has items %v", order)
+	}
+	want := append(copied, parentId, secondId, firstId)
+	if order, _ := itemOrder(t, db, targetId); !slices.Equal(order, want) {
+		t.Errorf("target list after the move = %v, want %v", order, want)
+	}
+	if parentOf(parentId) != 0 || parentOf(firstId) != parentId || parentOf(secondId) != parentId {
+		t.Error("moved items lost their parents")
+	}
+}
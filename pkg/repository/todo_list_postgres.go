@@ -2,13 +2,19 @@ package repository
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 	"todo"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/sirupsen/logrus"
 )
 
+const todoListColumns = "tl.id, tl.title, tl.description, ul.role, ul.position, tl.is_template, " +
+	"tl.version, tl.created_at, tl.updated_at, tl.archived_at, tl.deleted_at"
+
 type TodoListPostgres struct {
 	db *sqlx.DB
 }
@@ -18,84 +24,357 @@ func NewTodoListPostgres(db *sqlx.DB) *TodoListPostgres {
 }
 
 func (r *TodoListPostgres) Create(userId int, list todo.TodoList) (int, error) {
-	tx, err := r.db.Begin()
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := insertList(tx, userId, list)
 	if err != nil {
+		tx.Rollback()
 		return 0, err
 	}
 
+	return id, tx.Commit()
+}
+
+// insertList creates the list with the user as its owner.
+func insertList(tx *sqlx.Tx, userId int, list todo.TodoList) (int, error) {
 	var id int
-	createListQuery := fmt.Sprintf("INSERT INTO %s (title, description) VALUES ($1, $2) RETURNING id", todoListsTable)
-	row := tx.QueryRow(createListQuery, list.Title, list.Description)
+	createListQuery := fmt.Sprintf("INSERT INTO %s (title, description, is_template) VALUES ($1, $2, $3) RETURNING id", todoListsTable)
+	row := tx.QueryRow(createListQuery, list.Title, list.Description, list.IsTemplate)
 	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+
+	createUsersListQuery := fmt.Sprintf("INSERT INTO %s (user_id, list_id, role, position) VALUES ($1, $2, $3, %s)",
+		usersListsTable, nextPosition(usersListsTable, "user_id", "$1"))
+	if _, err := tx.Exec(createUsersListQuery, userId, id, todo.RoleOwner); err != nil {
+		return 0, err
+	}
+
+	return id, writeListActivity(tx, userId, id, todo.ActionListCreated, nil)
+}
+
+// Clone creates list for the user and copies all the items of the list listId
+// into it, keeping their order, subtasks and labels. edit may change each item
+// before it is copied.
+func (r *TodoListPostgres) Clone(userId, listId int, list todo.TodoList, edit func(item *todo.TodoItem) error) (int, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := listRole(tx, userId, listId); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	id, err := insertList(tx, userId, list)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	// parents come before their subtasks, so their copies exist when the subtasks are copied
+	var items []todo.TodoItem
+	itemsQuery := fmt.Sprintf(`WITH RECURSIVE tree AS (
+										SELECT ti.id, 0 AS depth FROM %s ti INNER JOIN %s li on li.item_id = ti.id
+										WHERE li.list_id = $1 AND ti.parent_id IS NULL AND ti.deleted_at IS NULL
+										UNION ALL
+										SELECT s.id, t.depth + 1 FROM %s s INNER JOIN tree t on s.parent_id = t.id WHERE s.deleted_at IS NULL
+									)
+									SELECT %s FROM tree INNER JOIN %s ti on ti.id = tree.id INNER JOIN %s li on li.item_id = ti.id
+									ORDER BY tree.depth, li.position, ti.id`,
+		todoItemsTable, listsItemsTable, todoItemsTable, todoItemColumns, todoItemsTable, listsItemsTable)
+	if err := tx.Select(&items, itemsQuery, listId); err != nil {
 		tx.Rollback()
 		return 0, err
 	}
 
-	createUsersListQuery := fmt.Sprintf("INSERT INTO %s (user_id, list_id) VALUES ($1, $2)", usersListsTable)
-	_, err = tx.Exec(createUsersListQuery, userId, id)
+	copies, err := copyItems(tx, userId, id, items, edit)
 	if err != nil {
 		tx.Rollback()
 		return 0, err
 	}
 
+	originals := make([]int64, 0, len(copies))
+	copyIds := make([]int64, 0, len(copies))
+	for original, copyId := range copies {
+		originals = append(originals, int64(original))
+		copyIds = append(copyIds, int64(copyId))
+	}
+	positionsQuery := fmt.Sprintf(`UPDATE %s dst SET position = src.position FROM %s src, unnest($1::int[], $2::int[]) AS c(original, copy)
+									WHERE src.item_id = c.original AND dst.item_id = c.copy`, listsItemsTable, listsItemsTable)
+	if _, err := tx.Exec(positionsQuery, pq.Array(originals), pq.Array(copyIds)); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	return id, tx.Commit()
 }
 
-func (r *TodoListPostgres) GetAll(userId int) ([]todo.TodoList, error) {
-	var lists []todo.TodoList
-	query := fmt.Sprintf("SELECT tl.id, tl.title, tl.description FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id WHERE ul.user_id = $1", todoListsTable, usersListsTable)
-	err := r.db.Select(&lists, query, userId)
+// GetAll returns a page of the user's lists in the user's order and the cursor of the next page.
+// Only archived lists are returned if the filter asks for them, none otherwise.
+func (r *TodoListPostgres) GetAll(userId int, filter todo.ListFilter, page todo.Page) ([]todo.TodoList, string, error) {
+	conditions := "ul.user_id = $1 AND tl.deleted_at IS NULL"
+	args := []interface{}{userId}
+
+	if filter.Archived {
+		conditions += " AND tl.archived_at IS NOT NULL"
+	} else {
+		conditions += " AND tl.archived_at IS NULL"
+	}
+
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor, "")
+		if err != nil {
+			return nil, "", err
+		}
+		conditions += " AND (ul.position, tl.id) > ($2, $3)"
+		args = append(args, c.Position, c.Id)
+	}
+
+	lists := make([]todo.TodoList, 0)
+	query := fmt.Sprintf(`SELECT %s FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id
+									WHERE %s ORDER BY ul.position, tl.id LIMIT $%d`, todoListColumns, todoListsTable, usersListsTable, conditions, len(args)+1)
+	args = append(args, page.Limit+1)
+	if err := r.db.Select(&lists, query, args...); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(lists) > page.Limit {
+		lists = lists[:page.Limit]
+		last := lists[len(lists)-1]
+		next = encodeCursor(cursor{Position: last.Position, Id: last.Id})
+	}
 
-	return lists, err
+	return lists, next, nil
 }
 
 func (r *TodoListPostgres) GetById(userId, listId int) (todo.TodoList, error) {
 	var list todo.TodoList
 
-	query := fmt.Sprintf(`SELECT tl.id, tl.title, tl.description FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id WHERE ul.user_id = $1 AND ul.list_id = $2`, todoListsTable, usersListsTable)
+	query := fmt.Sprintf(`SELECT %s FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id
+									WHERE ul.user_id = $1 AND ul.list_id = $2 AND tl.deleted_at IS NULL`, todoListColumns, todoListsTable, usersListsTable)
 	err := r.db.Get(&list, query, userId, listId)
 
-	return list, err
+	return list, notFound(err, "list not found")
 }
 
-func (r *TodoListPostgres) Delete(userdId, listId int) error {
+// Delete moves the list with its items to the trash and records the journal
+// entry that undoes it. A non-nil version restricts it to that version of the list.
+func (r *TodoListPostgres) Delete(userdId, listId int, version *int, journal todo.Journal) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
 
-	query := fmt.Sprintf("DELETE FROM %s tl USING %s ul WHERE tl.id = ul.list_id AND ul.user_id = $1 AND ul.list_id = $2", todoListsTable, usersListsTable)
+	if err := deleteList(tx, userdId, listId, version, journal); err != nil {
+		tx.Rollback()
+		return err
+	}
 
-	_, err := r.db.Exec(query, userdId, listId)
+	return tx.Commit()
+}
 
-	return err
+func deleteList(tx *sqlx.Tx, userdId, listId int, version *int, journal todo.Journal) error {
+	before, err := listSnapshot(tx, userdId, listId)
+	if err != nil {
+		return err
+	}
+
+	var deletedAt []time.Time
+	query := fmt.Sprintf(`UPDATE %s tl SET deleted_at = now() FROM %s ul
+									WHERE tl.id = ul.list_id AND ul.user_id = $1 AND ul.list_id = $2 AND ul.role = $3
+									AND tl.deleted_at IS NULL AND ($4::int IS NULL OR tl.version = $4) RETURNING tl.deleted_at`, todoListsTable, usersListsTable)
+	if err := tx.Select(&deletedAt, query, userdId, listId, todo.RoleOwner, version); err != nil {
+		return err
+	}
+	if len(deletedAt) == 0 {
+		return listChangeError(tx, userdId, listId, []string{todo.RoleOwner}, version)
+	}
+
+	state := listDeletion{DeletedAt: deletedAt[0]}
+	membersQuery := fmt.Sprintf("SELECT user_id, role, position FROM %s WHERE list_id = $1", usersListsTable)
+	if err := tx.Select(&state.Members, membersQuery, listId); err != nil {
+		return err
+	}
+
+	if err := writeListActivity(tx, userdId, listId, todo.ActionListDeleted, before); err != nil {
+		return err
+	}
+
+	return writeJournal(tx, journal, userdId, listId, todo.UndoListDelete, state)
 }
 
 func (r *TodoListPostgres) Update(userId, listId int, input todo.UpdateListInput) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	before, err := listSnapshot(tx, userId, listId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 
 	setValues := make([]string, 0)
 	args := make([]interface{}, 0)
 	argId := 1
 
 	if input.Title != nil {
-		setValues = append(setValues, fmt.Sprintf("tl.title = $%d", argId))
+		setValues = append(setValues, fmt.Sprintf("title = $%d", argId))
 		args = append(args, *input.Title)
 		argId++
 	}
 
 	if input.Description != nil {
-		setValues = append(setValues, fmt.Sprintf("tl.description = $%d", argId))
+		setValues = append(setValues, fmt.Sprintf("description = $%d", argId))
 		args = append(args, *input.Description)
 		argId++
 	}
 
+	if input.IsTemplate != nil {
+		setValues = append(setValues, fmt.Sprintf("is_template = $%d", argId))
+		args = append(args, *input.IsTemplate)
+		argId++
+	}
+
+	setValues = append(setValues, "version = tl.version + 1")
 	setQuery := strings.Join(setValues, ", ")
 
-	query := fmt.Sprintf("UPDATE %s tl SET %s FROM %s ul WHERE tl.id = ul.list_id AND ul.list_id = $%d AND ul.user_id=$%d", todoListsTable, setQuery, usersListsTable, argId, argId)
+	query := fmt.Sprintf(`UPDATE %s tl SET %s FROM %s ul WHERE tl.id = ul.list_id AND ul.list_id = $%d AND ul.user_id = $%d AND ul.role = ANY($%d)
+									AND tl.deleted_at IS NULL AND ($%d::int IS NULL OR tl.version = $%d)`,
+		todoListsTable, setQuery, usersListsTable, argId, argId+1, argId+2, argId+3, argId+3)
 
-	args = append(args, listId, userId)
+	args = append(args, listId, userId, pq.Array(editorRoles), input.Version)
 
 	logrus.Debugf("updateQuery: %s", query)
 	logrus.Debugf("seetValues: %s", args)
 
-	_, err := r.db.Exec(query, args...)
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := checkAffected(res, func() error {
+		return listChangeError(tx, userId, listId, editorRoles, input.Version)
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := writeListActivity(tx, userId, listId, todo.ActionListUpdated, before); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Move reorders the lists of the user, other members keep their own order.
+func (r *TodoListPostgres) Move(userId, listId int, input todo.MoveInput) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	lockQuery := fmt.Sprintf("SELECT list_id FROM %s WHERE user_id = $1 FOR UPDATE", usersListsTable)
+	var listIds []int
+	if err := tx.Select(&listIds, lockQuery, userId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !slices.Contains(listIds, listId) {
+		tx.Rollback()
+		return todo.NewError(todo.ErrNotFound, "list not found")
+	}
+
+	lists := ordering{table: usersListsTable, scopeColumn: "user_id", scope: userId, keyColumn: "list_id",
+		outside: "before and after must refer to another list of the user"}
+	if err := lists.move(tx, listId, input); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := writeActivity(tx, userId, listId, todo.ActionListMoved, todo.ActivityEntityList, listId, nil, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
 
-	return err
+// Archive hides the list from GetAll, or shows it again when archived is false.
+func (r *TodoListPostgres) Archive(userId, listId int, archived bool) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	before, err := listSnapshot(tx, userId, listId)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	query := fmt.Sprintf(`UPDATE %s tl SET archived_at = CASE WHEN $4 THEN COALESCE(tl.archived_at, now()) END, version = tl.version + 1 FROM %s ul
+									WHERE tl.id = ul.list_id AND ul.user_id = $1 AND ul.list_id = $2 AND ul.role = ANY($3) AND tl.deleted_at IS NULL`,
+		todoListsTable, usersListsTable)
+	res, err := tx.Exec(query, userId, listId, pq.Array(editorRoles), archived)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := checkAffected(res, func() error {
+		return listChangeError(tx, userId, listId, editorRoles, nil)
+	}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	action := todo.ActionListArchived
+	if !archived {
+		action = todo.ActionListUnarchived
+	}
+	if err := writeListActivity(tx, userId, listId, action, before); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Restore takes the list with its items out of the trash.
+func (r *TodoListPostgres) Restore(userId, listId int) error {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`UPDATE %s tl SET deleted_at = NULL, version = tl.version + 1 FROM %s ul
+									WHERE tl.id = ul.list_id AND ul.user_id = $1 AND ul.list_id = $2 AND ul.role = $3 AND tl.deleted_at IS NOT NULL`,
+		todoListsTable, usersListsTable)
+	res, err := tx.Exec(query, userId, listId, todo.RoleOwner)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := expectAffected(res, "list not found in trash"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := writeActivity(tx, userId, listId, todo.ActionListRestored, todo.ActivityEntityList, listId, nil, nil); err != nil {
+		tx.Rollback()
+		return err
+	}
 
+	return tx.Commit()
 }
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+	"todo"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type TrashPostgres struct {
+	db *sqlx.DB
+}
+
+func NewTrashPostgres(db *sqlx.DB) *TrashPostgres {
+	return &TrashPostgres{db: db}
+}
+
+// GetAll returns the trashed lists the user is a member of and the trashed items
+// of the other lists, most recently trashed first.
+func (r *TrashPostgres) GetAll(userId int) (todo.Trash, error) {
+	trash := todo.Trash{Lists: make([]todo.TodoList, 0), Items: make([]todo.TodoItem, 0)}
+
+	listsQuery := fmt.Sprintf(`SELECT %s FROM %s tl INNER JOIN %s ul on tl.id = ul.list_id
+									WHERE ul.user_id = $1 AND tl.deleted_at IS NOT NULL ORDER BY tl.deleted_at DESC, tl.id`,
+		todoListColumns, todoListsTable, usersListsTable)
+	if err := r.db.Select(&trash.Lists, listsQuery, userId); err != nil {
+		return trash, err
+	}
+
+	itemsQuery := fmt.Sprintf(`SELECT %s FROM %s ti INNER JOIN %s li on li.item_id = ti.id
+									INNER JOIN %s ul on ul.list_id = li.list_id
+									WHERE ul.user_id = $1 AND ti.deleted_at IS NOT NULL AND %s
+									AND NOT EXISTS (SELECT 1 FROM %s p WHERE p.id = ti.parent_id AND p.deleted_at = ti.deleted_at)
+									ORDER BY ti.deleted_at DESC, ti.id`,
+		todoItemColumns, todoItemsTable, listsItemsTable, usersListsTable, liveList("ul"), todoItemsTable)
+	if err := r.db.Select(&trash.Items, itemsQuery, userId); err != nil {
+		return trash, err
+	}
+
+	return trash, loadLabels(r.db, userId, trash.Items)
+}
+
+// Purge permanently removes the lists and items trashed before the given time
+// and returns how many of them there were. Expired change journal entries go
+// along with them.
+func (r *TrashPostgres) Purge(before time.Time) (int64, error) {
+	tx, err := r.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+
+	// lists_items rows go with the lists, the items have to be removed explicitly
+	listItemsQuery := fmt.Sprintf(`DELETE FROM %s WHERE id IN (
+										SELECT li.item_id FROM %s li INNER JOIN %s tl on tl.id = li.list_id WHERE tl.deleted_at < $1
+									)`, todoItemsTable, listsItemsTable, todoListsTable)
+	if _, err := tx.Exec(listItemsQuery, before); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	var purged int64
+	for _, table := range []string{todoListsTable, todoItemsTable} {
+		query := fmt.Sprintf("DELETE FROM %s WHERE deleted_at < $1", table)
+		res, err := tx.Exec(query, before)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		purged += affected
+	}
+
+	journalQuery := fmt.Sprintf("DELETE FROM %s WHERE expires_at < now()", changeJournalTable)
+	if _, err := tx.Exec(journalQuery); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return purged, tx.Commit()
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+	"todo"
+	"todo/pkg/testdb"
+)
+
+func TestPurge(t *testing.T) {
+	db := testdb.Open(t)
+	lists := NewTodoListPostgres(db)
+	items := NewTodoItemPostgres(db)
+	userId := testdb.CreateUser(t, db, "user")
+
+	var journals int
+	journal := func() todo.Journal {
+		journals++
+		return todo.Journal{TokenHash: fmt.Sprintf("token-%d", journals), ExpiresAt: time.Now().Add(time.Hour)}
+	}
+	// trashedAgo backdates when a row was trashed
+	trashedAgo := func(table string, id int, ago time.Duration) {
+		t.Helper()
+		query := fmt.Sprintf("UPDATE %s SET deleted_at = $1 WHERE id = $2", table)
+		if _, err := db.Exec(query, time.Now().Add(-ago), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(table string, id int) bool {
+		t.Helper()
+		var exists bool
+		query := fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1)", table)
+		if err := db.Get(&exists, query, id); err != nil {
+			t.Fatal(err)
+		}
+		return exists
+	}
+
+	listId, err := lists.Create(userId, todo.TodoList{Title: "kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	liveId, err := items.Create(userId, listId, todo.TodoItem{Title: "live"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldItemId, err := items.Create(userId, listId, todo.TodoItem{Title: "trashed long ago"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recentItemId, err := items.Create(userId, listId, todo.TodoItem{Title: "trashed recently"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldListId, err := lists.Create(userId, todo.TodoList{Title: "trashed long ago"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldListItemId, err := items.Create(userId, oldListId, todo.TodoItem{Title: "in a list trashed long ago"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int{oldItemId, recentItemId} {
+		if err := items.Delete(userId, id, nil, journal()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := lists.Delete(userId, oldListId, nil, journal()); err != nil {
+		t.Fatal(err)
+	}
+	trashedAgo(todoItemsTable, oldItemId, 2*time.Hour)
+	trashedAgo(todoItemsTable, recentItemId, 30*time.Minute)
+	trashedAgo(todoListsTable, oldListId, 2*time.Hour)
+
+	purged, err := NewTrashPostgres(db).Purge(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 2 {
+		t.Errorf("purged %d rows, want the old item and list", purged)
+	}
+
+	tests := []struct {
+		name  string
+		table string
+		id    int
+		want  bool
+	}{
+		{"live item", todoItemsTable, liveId, true},
+		{"item trashed recently", todoItemsTable, recentItemId, true},
+		{"list", todoListsTable, listId, true},
+		{"item trashed long ago", todoItemsTable, oldItemId, false},
+		{"list trashed long ago", todoListsTable, oldListId, false},
+		{"item of a list trashed long ago", todoItemsTable, oldListItemId, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exists(tt.table, tt.id); got != tt.want {
+				t.Errorf("exists = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"todo"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const deliveryColumns = "d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.last_attempt_at, " +
+	"d.response_status, d.error, d.created_at"
+
+type WebhookPostgres struct {
+	db *sqlx.DB
+}
+
+func NewWebhookPostgres(db *sqlx.DB) *WebhookPostgres {
+	return &WebhookPostgres{db: db}
+}
+
+// webhookRow scans the events array, which the todo package knows nothing about.
+type webhookRow struct {
+	todo.Webhook
+	Events pq.StringArray `db:"events"`
+}
+
+func (r *WebhookPostgres) Create(webhook todo.Webhook) (int, error) {
+	var id int
+	query := fmt.Sprintf("INSERT INTO %s (list_id, created_by, url, secret, events) values ($1, $2, $3, $4, $5) RETURNING id", webhooksTable)
+	row := r.db.QueryRow(query, webhook.ListId, webhook.CreatedBy, webhook.URL, webhook.Secret, pq.Array(webhook.Events))
+	err := row.Scan(&id)
+
+	return id, err
+}
+
+func (r *WebhookPostgres) GetAll(listId int) ([]todo.Webhook, error) {
+	var rows []webhookRow
+	query := fmt.Sprintf("SELECT id, list_id, created_by, url, events, created_at FROM %s WHERE list_id = $1 ORDER BY id", webhooksTable)
+	if err := r.db.Select(&rows, query, listId); err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]todo.Webhook, len(rows))
+	for i, row := range rows {
+		webhooks[i] = row.Webhook
+		webhooks[i].Events = row.Events
+	}
+
+	return webhooks, nil
+}
+
+func (r *WebhookPostgres) Delete(listId, webhookId int) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1 AND list_id = $2", webhooksTable)
+	res, err := r.db.Exec(query, webhookId, listId)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(res, "webhook not found")
+}
+
+// GetDeliveries returns a page of the deliveries of a webhook, latest first.
+func (r *WebhookPostgres) GetDeliveries(listId, webhookId int, page todo.Page) ([]todo.WebhookDelivery, string, error) {
+	conditions := "w.list_id = $1 AND w.id = $2"
+	args := []interface{}{listId, webhookId}
+
+	if page.Cursor != "" {
+		c, err := decodeCursor(page.Cursor, "")
+		if err != nil {
+			return nil, "", err
+		}
+		conditions += " AND d.id < $3"
+		args = append(args, c.Id)
+	}
+
+	deliveries := make([]todo.WebhookDelivery, 0)
+	query := fmt.Sprintf(`SELECT %s FROM %s d INNER JOIN %s w on w.id = d.webhook_id
+									WHERE %s ORDER BY d.id DESC LIMIT $%d`, deliveryColumns, webhookDeliveriesTable, webhooksTable, conditions, len(args)+1)
+	args = append(args, page.Limit+1)
+	if err := r.db.Select(&deliveries, query, args...); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(deliveries) > page.Limit {
+		deliveries = deliveries[:page.Limit]
+		next = encodeCursor(cursor{Id: deliveries[len(deliveries)-1].Id})
+	}
+
+	return deliveries, next, nil
+}
+
+// Redeliver queues the payload of a delivery to be sent again as a new delivery.
+func (r *WebhookPostgres) Redeliver(listId, webhookId, deliveryId int) (int, error) {
+	var id int
+	query := fmt.Sprintf(`INSERT INTO %s (webhook_id, event_type, payload)
+									SELECT d.webhook_id, d.event_type, d.payload FROM %s d INNER JOIN %s w on w.id = d.webhook_id
+									WHERE w.list_id = $1 AND w.id = $2 AND d.id = $3 RETURNING id`,
+		webhookDeliveriesTable, webhookDeliveriesTable, webhooksTable)
+	err := r.db.Get(&id, query, listId, webhookId, deliveryId)
+
+	return id, notFound(err, "delivery not found")
+}
+
+// ClaimDue returns up to limit pending deliveries that are due, with the URL
+// and secret of their webhooks. They are not due again for the lease, so other
+// dispatchers skip them meanwhile and they are retried if this one goes away.
+func (r *WebhookPostgres) ClaimDue(limit int, lease time.Duration) ([]todo.WebhookDelivery, error) {
+	deliveries := make([]todo.WebhookDelivery, 0)
+	query := fmt.Sprintf(`WITH due AS (
+										SELECT id FROM %s WHERE status = $1 AND next_attempt_at <= now()
+										ORDER BY next_attempt_at LIMIT $2 FOR UPDATE SKIP LOCKED
+									)
+									UPDATE %s d SET next_attempt_at = now() + $3 * interval '1 second' FROM due, %s w
+									WHERE d.id = due.id AND w.id = d.webhook_id
+									RETURNING %s, w.url, w.secret`,
+		webhookDeliveriesTable, webhookDeliveriesTable, webhooksTable, deliveryColumns)
+	err := r.db.Select(&deliveries, query, todo.DeliveryPending, limit, lease.Seconds())
+
+	return deliveries, err
+}
+
+// RecordAttempt logs an attempt to send a delivery. A delivery without an error
+// succeeded, one without a next attempt failed for good.
+func (r *WebhookPostgres) RecordAttempt(deliveryId int, attempt todo.DeliveryAttempt) error {
+	status := todo.DeliveryPending
+	switch {
+	case attempt.Error == nil:
+		status = todo.DeliverySucceeded
+	case attempt.NextAttemptAt == nil:
+		status = todo.DeliveryFailed
+	}
+
+	query := fmt.Sprintf(`UPDATE %s SET status = $2, attempts = attempts + 1, last_attempt_at = now(), response_status = $3, error = $4,
+									next_attempt_at = COALESCE($5, next_attempt_at) WHERE id = $1`, webhookDeliveriesTable)
+	_, err := r.db.Exec(query, deliveryId, status, attempt.ResponseStatus, attempt.Error, attempt.NextAttemptAt)
+
+	return err
+}
+
+// enqueueDeliveries queues a change to an item for the webhooks of its list that subscribe to it.
+func enqueueDeliveries(tx *sqlx.Tx, eventType string, userId, itemId int) error {
+	var webhooks []struct {
+		Id        int `db:"id"`
+		CreatedBy int `db:"created_by"`
+	}
+	webhooksQuery := fmt.Sprintf(`SELECT w.id, w.created_by FROM %s w INNER JOIN %s li on li.list_id = w.list_id
+									WHERE li.item_id = $1 AND $2 = ANY(w.events) ORDER BY w.id`, webhooksTable, listsItemsTable)
+	if err := tx.Select(&webhooks, webhooksQuery, itemId, eventType); err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	var item todo.TodoItem
+	itemQuery := fmt.Sprintf("SELECT %s FROM %s ti INNER JOIN %s li on li.item_id = ti.id WHERE ti.id = $1",
+		todoItemColumns, todoItemsTable, listsItemsTable)
+	if err := tx.Get(&item, itemQuery, itemId); err != nil {
+		return err
+	}
+	event := todo.Event{Type: eventType, ListId: item.ListId, ItemId: itemId, UserId: userId, At: time.Now()}
+
+	query := fmt.Sprintf("INSERT INTO %s (webhook_id, event_type, payload) VALUES ($1, $2, $3)", webhookDeliveriesTable)
+	for _, webhook := range webhooks {
+		// labels are private, the payload carries those of the webhook's creator
+		items := []todo.TodoItem{item}
+		if err := loadLabels(tx, webhook.CreatedBy, items); err != nil {
+			return err
+		}
+
+		payload, err := json.Marshal(todo.WebhookPayload{Event: event, Item: &items[0]})
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(query, webhook.Id, eventType, string(payload)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
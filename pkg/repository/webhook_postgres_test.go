@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"testing"
+	"time"
+	"todo"
+	"todo/pkg/testdb"
+)
+
+// deliveryFixture creates a list with a webhook on item creation and an item,
+// and returns the webhook and the delivery of the item.
+func deliveryFixture(t *testing.T, r *WebhookPostgres) (listId, webhookId int, delivery todo.WebhookDelivery) {
+	t.Helper()
+
+	userId := testdb.CreateUser(t, r.db, "owner")
+	listId, err := NewTodoListPostgres(r.db).Create(userId, todo.TodoList{Title: "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhookId, err = r.Create(todo.Webhook{ListId: listId, CreatedBy: userId, URL: "https://example.com/hook", Secret: "s",
+		Events: []string{todo.EventItemCreated}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTodoItemPostgres(r.db).Create(userId, listId, todo.TodoItem{Title: "item"}); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := r.ClaimDue(10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("claimed %d deliveries, want 1", len(deliveries))
+	}
+
+	return listId, webhookId, deliveries[0]
+}
+
+func getDelivery(t *testing.T, r *WebhookPostgres, listId, webhookId, deliveryId int) todo.WebhookDelivery {
+	t.Helper()
+
+	deliveries, _, err := r.GetDeliveries(listId, webhookId, todo.Page{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range deliveries {
+		if d.Id == deliveryId {
+			return d
+		}
+	}
+	t.Fatalf("delivery %d not found", deliveryId)
+	return todo.WebhookDelivery{}
+}
+
+func TestRecordAttempt(t *testing.T) {
+	failure := "unexpected response status 500"
+	status := 500
+	next := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name    string
+		attempt todo.DeliveryAttempt
+		want    string
+	}{
+		{"succeeded", todo.DeliveryAttempt{ResponseStatus: &status}, todo.DeliverySucceeded},
+		{"retried", todo.DeliveryAttempt{ResponseStatus: &status, Error: &failure, NextAttemptAt: &next}, todo.DeliveryPending},
+		{"given up", todo.DeliveryAttempt{ResponseStatus: &status, Error: &failure}, todo.DeliveryFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewWebhookPostgres(testdb.Open(t))
+			listId, webhookId, delivery := deliveryFixture(t, r)
+
+			if err := r.RecordAttempt(delivery.Id, tt.attempt); err != nil {
+				t.Fatal(err)
+			}
+
+			got := getDelivery(t, r, listId, webhookId, delivery.Id)
+			if got.Status != tt.want {
+				t.Errorf("status = %s, want %s", got.Status, tt.want)
+			}
+			if got.Attempts != 1 {
+				t.Errorf("attempts = %d, want 1", got.Attempts)
+			}
+			if tt.attempt.NextAttemptAt != nil && !got.NextAttemptAt.Equal(next) {
+				t.Errorf("next attempt at %v, want %v", got.NextAttemptAt, next)
+			}
+		})
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	r := NewWebhookPostgres(testdb.Open(t))
+	listId, webhookId, delivery := deliveryFixture(t, r)
+
+	failure := "unexpected response status 500"
+	if err := r.RecordAttempt(delivery.Id, todo.DeliveryAttempt{Error: &failure}); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := r.Redeliver(listId, webhookId, delivery.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == delivery.Id {
+		t.Fatal("redelivery reused the original delivery")
+	}
+
+	original := getDelivery(t, r, listId, webhookId, delivery.Id)
+	if original.Status != todo.DeliveryFailed {
+		t.Errorf("original status = %s, want %s", original.Status, todo.DeliveryFailed)
+	}
+
+	redelivery := getDelivery(t, r, listId, webhookId, id)
+	if redelivery.Status != todo.DeliveryPending || redelivery.Attempts != 0 {
+		t.Errorf("redelivery status = %s after %d attempts, want a new pending delivery", redelivery.Status, redelivery.Attempts)
+	}
+	if string(redelivery.Payload) != string(original.Payload) || redelivery.EventType != original.EventType {
+		t.Errorf("redelivery = %s %s, want %s %s", redelivery.EventType, redelivery.Payload, original.EventType, original.Payload)
+	}
+
+	if _, err := r.Redeliver(listId+1, webhookId, delivery.Id); err == nil {
+		t.Error("redelivered from another list")
+	}
+}
+
+func TestItemChangesEnqueueDeliveries(t *testing.T) {
+	r := NewWebhookPostgres(testdb.Open(t))
+	items := NewTodoItemPostgres(r.db)
+
+	userId := testdb.CreateUser(t, r.db, "owner")
+	listId, err := NewTodoListPostgres(r.db).Create(userId, todo.TodoList{Title: "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentId, err := items.Create(userId, listId, todo.TodoItem{Title: "parent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	itemId, err := items.Create(userId, listId, todo.TodoItem{Title: "item"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(todo.Webhook{ListId: listId, CreatedBy: userId, URL: "https://example.com/hook", Secret: "s",
+		Events: []string{todo.EventItemCreated, todo.EventItemUpdated}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		change func() error
+		want   string
+	}{
+		{"archive", func() error { return items.Archive(userId, itemId, true) }, todo.EventItemUpdated},
+		{"unarchive", func() error { return items.Archive(userId, itemId, false) }, todo.EventItemUpdated},
+		{"set parent", func() error { return items.SetParent(userId, itemId, &parentId) }, todo.EventItemUpdated},
+		{"move", func() error { return items.Move(userId, parentId, todo.MoveInput{After: &itemId}) }, todo.EventItemUpdated},
+		{"restore", func() error {
+			journal := todo.Journal{TokenHash: "restore", ExpiresAt: time.Now().Add(time.Minute)}
+			if err := items.Delete(userId, itemId, nil, journal); err != nil {
+				return err
+			}
+			return items.Restore(userId, itemId)
+		}, todo.EventItemCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(); err != nil {
+				t.Fatal(err)
+			}
+
+			deliveries, err := r.ClaimDue(10, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deliveries) != 1 || deliveries[0].EventType != tt.want {
+				var got []string
+				for _, d := range deliveries {
+					got = append(got, d.EventType)
+				}
+				t.Errorf("deliveries = %v, want [%s]", got, tt.want)
+			}
+		})
+	}
+}
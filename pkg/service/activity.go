@@ -0,0 +1,28 @@
+package service
+
+import (
+	"todo"
+	"todo/pkg/repository"
+)
+
+type ActivityService struct {
+	repo       repository.Activity
+	memberRepo repository.ListMember
+	pagination Pagination
+}
+
+func NewActivityService(repo repository.Activity, memberRepo repository.ListMember, pagination Pagination) *ActivityService {
+	return &ActivityService{repo: repo, memberRepo: memberRepo, pagination: pagination}
+}
+
+func (s *ActivityService) GetByList(userId, listId int, page todo.Page) ([]todo.Activity, string, error) {
+	if _, err := s.memberRepo.GetRole(userId, listId); err != nil {
+		// list does not exists or user is not a member
+		return nil, "", err
+	}
+	return s.repo.GetByList(listId, s.pagination.apply(page))
+}
+
+func (s *ActivityService) GetFeed(userId int, page todo.Page) ([]todo.Activity, string, error) {
+	return s.repo.GetFeed(userId, s.pagination.apply(page))
+}
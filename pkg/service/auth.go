@@ -1,78 +1,240 @@
 package service
 
 import (
-	"crypto/sha1"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"fmt"
 	"time"
 	"todo"
 	"todo/pkg/repository"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
 )
 
 const (
-	salt       = "h328yfuegcvg38974628gcbj"
-	signingKey = "h328yfuegcvg38974628gcbj"
-	tokenTTL   = 12 * time.Hour
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
 )
 
 type tokenClaims struct {
-	jwt.StandardClaims
-	UserId int `json:"user_id"`
+	jwt.RegisteredClaims
+	UserId    int `json:"user_id"`
+	SessionId int `json:"session_id"`
 }
 
+// Tokens is the pair issued on sign-in and on every refresh.
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+var (
+	errInvalidRefreshToken = todo.NewError(todo.ErrUnauthorized, "invalid refresh token")
+	errSessionRevoked      = todo.NewError(todo.ErrUnauthorized, "session has been revoked")
+)
+
+var errInvalidCredentials = todo.NewError(todo.ErrUnauthorized, "invalid username or password")
+
 type AuthService struct {
-	repo repository.Authorization
+	repo   repository.Authorization
+	hasher PasswordHasher
+	keys   *KeySet
 }
 
-func NewAuthService(repo repository.Authorization) *AuthService {
-	return &AuthService{repo: repo}
+func NewAuthService(repo repository.Authorization, hasher PasswordHasher, keys *KeySet) *AuthService {
+	return &AuthService{repo: repo, hasher: hasher, keys: keys}
 }
 
 func (s *AuthService) CreateUser(user todo.User) (int, error) {
-	user.Password = generatePasswordHash(user.Password)
+	hash, err := s.hasher.Hash(user.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	user.Password = hash
 	return s.repo.CreateUser(user)
 }
 
-func (s *AuthService) GenerateToken(username, password string) (string, error) {
-	user, err := s.repo.GetUser(username, generatePasswordHash(password))
+func (s *AuthService) GenerateToken(username, password string) (Tokens, error) {
+	user, err := s.repo.GetUser(username)
+	if errors.Is(err, todo.ErrNotFound) {
+		return Tokens{}, errInvalidCredentials
+	}
 	if err != nil {
-		return "", err
+		return Tokens{}, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &tokenClaims{
-		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(tokenTTL).Unix(),
-			IssuedAt:  time.Now().Unix(),
-		},
-		user.Id,
+	if err := s.verifyPassword(user, password); err != nil {
+		return Tokens{}, err
+	}
+
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	sessionId, err := s.repo.CreateSession(todo.Session{
+		UserId:           user.Id,
+		RefreshTokenHash: hashRefreshToken(refreshToken),
+		ExpiresAt:        time.Now().Add(refreshTokenTTL),
 	})
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	accessToken, err := s.newAccessToken(user.Id, sessionId)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new token pair. The refresh token
+// is rotated, so the one passed in cannot be used again.
+func (s *AuthService) RefreshToken(refreshToken string) (Tokens, error) {
+	session, err := s.activeSession(refreshToken)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	newRefreshToken, err := generateRefreshToken()
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	err = s.repo.RotateSession(session.Id, session.RefreshTokenHash, hashRefreshToken(newRefreshToken), time.Now().Add(refreshTokenTTL))
+	if errors.Is(err, todo.ErrNotFound) {
+		// the token has been used by a concurrent refresh
+		return Tokens{}, errInvalidRefreshToken
+	}
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	accessToken, err := s.newAccessToken(session.UserId, session.Id)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	return Tokens{AccessToken: accessToken, RefreshToken: newRefreshToken}, nil
+}
+
+// SignOut revokes the session the refresh token belongs to. Access tokens issued
+// for that session stop being accepted right away.
+func (s *AuthService) SignOut(refreshToken string) error {
+	session, err := s.activeSession(refreshToken)
+	if err != nil {
+		return err
+	}
 
-	return token.SignedString([]byte(signingKey))
+	return s.repo.RevokeSession(session.Id)
 }
 
 func (s *AuthService) ParseToken(accessToken string) (int, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &tokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(signingKey), nil
-	})
+	token, err := jwt.ParseWithClaims(accessToken, &tokenClaims{}, s.keys.Keyfunc)
 	if err != nil {
-		return 0, err
+		return 0, todo.NewError(todo.ErrUnauthorized, err.Error())
 	}
 	claims, ok := token.Claims.(*tokenClaims)
 	if !ok {
 		return 0, errors.New("token claims are not of type *tokenClaims")
 	}
 
+	session, err := s.repo.GetSession(claims.SessionId)
+	if errors.Is(err, todo.ErrNotFound) {
+		return 0, todo.NewError(todo.ErrUnauthorized, "session not found")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if session.UserId != claims.UserId {
+		return 0, todo.NewError(todo.ErrUnauthorized, "session not found")
+	}
+	if session.RevokedAt != nil {
+		return 0, errSessionRevoked
+	}
+
 	return claims.UserId, nil
 }
 
-func generatePasswordHash(password string) string {
-	hash := sha1.New()
-	hash.Write([]byte(password))
+// JWKS returns the public keys other services can verify access tokens with.
+func (s *AuthService) JWKS() JWKS {
+	return s.keys.JWKS()
+}
+
+func (s *AuthService) newAccessToken(userId, sessionId int) (string, error) {
+	now := time.Now()
+
+	return s.keys.Sign(&tokenClaims{
+		jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		userId,
+		sessionId,
+	})
+}
+
+func (s *AuthService) activeSession(refreshToken string) (todo.Session, error) {
+	session, err := s.repo.GetSessionByRefreshToken(hashRefreshToken(refreshToken))
+	if errors.Is(err, todo.ErrNotFound) {
+		return todo.Session{}, errInvalidRefreshToken
+	}
+	if err != nil {
+		return todo.Session{}, err
+	}
+	if session.RevokedAt != nil {
+		return todo.Session{}, errSessionRevoked
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return todo.Session{}, todo.NewError(todo.ErrUnauthorized, "refresh token expired")
+	}
+
+	return session, nil
+}
+
+// verifyPassword checks the password against the stored hash and, once it is known
+// to be correct, upgrades hashes made by an older algorithm or with weaker parameters.
+// The upgrade is best effort, failing it does not fail the sign-in.
+func (s *AuthService) verifyPassword(user todo.User, password string) error {
+	ok, err := hasherFor(user.Password).Verify(password, user.Password)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errInvalidCredentials
+	}
+
+	if !s.hasher.NeedsRehash(user.Password) {
+		return nil
+	}
+
+	hash, err := s.hasher.Hash(password)
+	if err == nil {
+		err = s.repo.UpdatePasswordHash(user.Id, hash)
+	}
+	if err != nil {
+		logrus.Errorf("error occured while rehashing password: %s", err.Error())
+	}
+
+	return nil
+}
+
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
 
-	return fmt.Sprintf("%x", hash.Sum([]byte(salt)))
+// hashRefreshToken is what gets stored, so a leaked sessions table does not leak usable tokens.
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
 }
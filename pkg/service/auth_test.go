@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"todo"
+	"todo/pkg/repository"
+	"todo/pkg/testdb"
+)
+
+func newTestAuthService(t *testing.T) *AuthService {
+	t.Helper()
+
+	t.Setenv("TEST_JWT_SECRET", "jwt-secret")
+	keys, err := NewKeySet("hmac", []KeyConfig{{Id: "hmac", Algorithm: "HS256", SecretEnv: "TEST_JWT_SECRET"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewAuthService(repository.NewAuthPostgres(testdb.Open(t)), newArgon2idHasher(), keys)
+	if _, err := s.CreateUser(todo.User{Name: "user", Username: "user", Password: "qwerty"}); err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func expectUnauthorized(t *testing.T, what string, err error) {
+	t.Helper()
+
+	if !errors.Is(err, todo.ErrUnauthorized) {
+		t.Errorf("%s: err = %v, want unauthorized", what, err)
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s := newTestAuthService(t)
+
+	first, err := s.GenerateToken("user", "qwerty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userId, err := s.ParseToken(first.AccessToken)
+	if err != nil {
+		t.Fatalf("access token: %v", err)
+	}
+
+	second, err := s.RefreshToken(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("refresh token was not rotated")
+	}
+	if id, err := s.ParseToken(second.AccessToken); err != nil || id != userId {
+		t.Errorf("refreshed access token = %d, %v, want user %d", id, err, userId)
+	}
+
+	// a rotated refresh token is spent
+	_, err = s.RefreshToken(first.RefreshToken)
+	expectUnauthorized(t, "reused refresh token", err)
+
+	if _, err := s.RefreshToken(second.RefreshToken); err != nil {
+		t.Errorf("current refresh token: %v", err)
+	}
+}
+
+func TestSignOutRevokesSession(t *testing.T) {
+	s := newTestAuthService(t)
+
+	tokens, err := s.GenerateToken("user", "qwerty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.GenerateToken("user", "qwerty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SignOut(tokens.RefreshToken); err != nil {
+		t.Fatalf("sign out: %v", err)
+	}
+
+	_, err = s.ParseToken(tokens.AccessToken)
+	expectUnauthorized(t, "access token of the revoked session", err)
+	_, err = s.RefreshToken(tokens.RefreshToken)
+	expectUnauthorized(t, "refresh token of the revoked session", err)
+	err = s.SignOut(tokens.RefreshToken)
+	expectUnauthorized(t, "signing out again", err)
+
+	// other sessions of the user are not affected
+	if _, err := s.ParseToken(other.AccessToken); err != nil {
+		t.Errorf("access token of another session: %v", err)
+	}
+}
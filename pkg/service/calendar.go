@@ -0,0 +1,202 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"todo"
+	"todo/pkg/repository"
+	"unicode/utf8"
+)
+
+const (
+	calendarProductId = "-//todo-app//todo//EN"
+	calendarFeedName  = "Todo"
+	// calendarLineLimit is how many octets a content line may have before it
+	// is folded onto the next one.
+	calendarLineLimit = 75
+)
+
+// calendarPriorities maps item priorities to iCalendar ones, where 1 is the
+// highest, 9 the lowest and 0 undefined.
+var calendarPriorities = map[int]int{
+	todo.PriorityNone:   0,
+	todo.PriorityLow:    9,
+	todo.PriorityMedium: 5,
+	todo.PriorityHigh:   1,
+}
+
+var calendarTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", "")
+
+// CalendarService renders the items with due dates as RFC 5545 to-dos, for a
+// single list or as a feed of all the lists of a user that calendar apps
+// subscribe to with a secret token instead of a JWT.
+type CalendarService struct {
+	repo     repository.Calendar
+	listRepo repository.TodoList
+}
+
+func NewCalendarService(repo repository.Calendar, listRepo repository.TodoList) *CalendarService {
+	return &CalendarService{repo: repo, listRepo: listRepo}
+}
+
+func (s *CalendarService) GetListCalendar(userId, listId int) ([]byte, error) {
+	list, err := s.listRepo.GetById(userId, listId)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.repo.GetListItems(userId, listId)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeCalendar(list.Title, items), nil
+}
+
+// CreateFeed returns the token of a new calendar feed for the user. It
+// replaces the feed the user had, whose URL stops working.
+func (s *CalendarService) CreateFeed(userId int) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	if err := s.repo.SetFeed(userId, hashFeedToken(token)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s *CalendarService) DeleteFeed(userId int) error {
+	return s.repo.DeleteFeed(userId)
+}
+
+// GetFeed renders the calendar feed with the token.
+func (s *CalendarService) GetFeed(token string) ([]byte, error) {
+	userId, err := s.repo.GetFeedUser(hashFeedToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.repo.GetUserItems(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeCalendar(calendarFeedName, items), nil
+}
+
+// hashFeedToken is what gets stored, as the token alone grants access to the feed.
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// calendarWriter writes iCalendar content lines.
+type calendarWriter struct {
+	buf bytes.Buffer
+}
+
+// prop writes a property, folding it into lines of at most calendarLineLimit
+// octets without splitting a character.
+func (w *calendarWriter) prop(name, value string) {
+	line := name + ":" + value
+	limit := calendarLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.buf.WriteString(line[:cut])
+		w.buf.WriteString("\r\n ")
+		line = line[cut:]
+		// continuation lines start with the space
+		limit = calendarLineLimit - 1
+	}
+	w.buf.WriteString(line)
+	w.buf.WriteString("\r\n")
+}
+
+func (w *calendarWriter) text(name, value string) {
+	w.prop(name, calendarTextEscaper.Replace(value))
+}
+
+func (w *calendarWriter) timestamp(name string, t time.Time) {
+	w.prop(name, t.UTC().Format("20060102T150405Z"))
+}
+
+func encodeCalendar(name string, items []todo.TodoItem) []byte {
+	var w calendarWriter
+
+	w.prop("BEGIN", "VCALENDAR")
+	w.prop("VERSION", "2.0")
+	w.prop("PRODID", calendarProductId)
+	w.prop("CALSCALE", "GREGORIAN")
+	w.text("NAME", name)
+	w.text("X-WR-CALNAME", name)
+	for _, item := range items {
+		encodeTodo(&w, item)
+	}
+	w.prop("END", "VCALENDAR")
+
+	return w.buf.Bytes()
+}
+
+func encodeTodo(w *calendarWriter, item todo.TodoItem) {
+	w.prop("BEGIN", "VTODO")
+	w.prop("UID", itemUID(item.Id))
+	w.timestamp("DTSTAMP", item.UpdatedAt)
+	w.timestamp("CREATED", item.CreatedAt)
+	w.timestamp("LAST-MODIFIED", item.UpdatedAt)
+	w.prop("SEQUENCE", strconv.Itoa(item.Version-1))
+	w.text("SUMMARY", item.Title)
+	if item.Description != "" {
+		w.text("DESCRIPTION", item.Description)
+	}
+	if len(item.Labels) > 0 {
+		categories := make([]string, len(item.Labels))
+		for i, label := range item.Labels {
+			categories[i] = calendarTextEscaper.Replace(label)
+		}
+		w.prop("CATEGORIES", strings.Join(categories, ","))
+	}
+	if item.ParentId != nil {
+		w.prop("RELATED-TO", itemUID(*item.ParentId))
+	}
+	if priority := calendarPriorities[item.Priority]; priority != 0 {
+		w.prop("PRIORITY", strconv.Itoa(priority))
+	}
+	w.timestamp("DUE", *item.DueAt)
+
+	if item.Done {
+		w.prop("STATUS", "COMPLETED")
+		w.prop("PERCENT-COMPLETE", "100")
+		if item.CompletedAt != nil {
+			w.timestamp("COMPLETED", *item.CompletedAt)
+		}
+	} else {
+		w.prop("STATUS", "NEEDS-ACTION")
+		if item.RemindAt != nil {
+			w.prop("BEGIN", "VALARM")
+			w.prop("ACTION", "DISPLAY")
+			w.text("DESCRIPTION", item.Title)
+			w.timestamp("TRIGGER;VALUE=DATE-TIME", *item.RemindAt)
+			w.prop("END", "VALARM")
+		}
+	}
+
+	w.prop("END", "VTODO")
+}
+
+func itemUID(itemId int) string {
+	return fmt.Sprintf("item-%d@todo-app", itemId)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"todo"
+)
+
+func TestCalendarWriterText(t *testing.T) {
+	a := func(n int) string { return strings.Repeat("a", n) }
+
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"short", "milk", "SUMMARY:milk\r\n"},
+		{"75 octets", a(67), "SUMMARY:" + a(67) + "\r\n"},
+		{"76 octets", a(68), "SUMMARY:" + a(67) + "\r\n a\r\n"},
+		// continuation lines have room for 74 octets after the space
+		{"three lines", a(67 + 74 + 5), "SUMMARY:" + a(67) + "\r\n " + a(74) + "\r\n " + a(5) + "\r\n"},
+		{"two-octet character at the limit", a(66) + "é", "SUMMARY:" + a(66) + "\r\n é\r\n"},
+		{"three-octet character at the limit", a(65) + "€", "SUMMARY:" + a(65) + "\r\n €\r\n"},
+		{"escaping", "a\\b;c,d\ne\r\nf\rg", "SUMMARY:a\\\\b\\;c\\,d\\ne\\nfg\r\n"},
+		// folding works on the escaped text and may split an escape
+		{"escape at the limit", a(66) + ",", "SUMMARY:" + a(66) + "\\\r\n ,\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var w calendarWriter
+			w.text("SUMMARY", tt.value)
+			if got := w.buf.String(); got != tt.want {
+				t.Errorf("got  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeCalendar(t *testing.T) {
+	plus2 := time.FixedZone("+02", 2*60*60)
+	created := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	updated := time.Date(2026, 3, 2, 11, 30, 0, 0, time.UTC)
+	due := time.Date(2026, 3, 5, 18, 0, 0, 0, plus2)
+	remind := time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)
+	completed := time.Date(2026, 3, 4, 8, 15, 0, 0, time.UTC)
+	parentId := 3
+
+	header := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//todo-app//todo//EN",
+		"CALSCALE:GREGORIAN",
+		"NAME:Home\\, shared",
+		"X-WR-CALNAME:Home\\, shared",
+	}
+
+	tests := []struct {
+		name string
+		item todo.TodoItem
+		want []string
+	}{
+		{
+			name: "open with reminder",
+			item: todo.TodoItem{Id: 7, ParentId: &parentId, Title: "Buy milk, eggs", Description: "2%; fresh\nfrom the store",
+				Priority: todo.PriorityHigh, Labels: []string{"home", "errands;weekly"}, DueAt: &due, RemindAt: &remind,
+				Version: 3, CreatedAt: created, UpdatedAt: updated},
+			want: []string{
+				"BEGIN:VTODO",
+				"UID:item-7@todo-app",
+				"DTSTAMP:20260302T113000Z",
+				"CREATED:20260301T100000Z",
+				"LAST-MODIFIED:20260302T113000Z",
+				"SEQUENCE:2",
+				"SUMMARY:Buy milk\\, eggs",
+				"DESCRIPTION:2%\\; fresh\\nfrom the store",
+				"CATEGORIES:home,errands\\;weekly",
+				"RELATED-TO:item-3@todo-app",
+				"PRIORITY:1",
+				"DUE:20260305T160000Z",
+				"STATUS:NEEDS-ACTION",
+				"BEGIN:VALARM",
+				"ACTION:DISPLAY",
+				"DESCRIPTION:Buy milk\\, eggs",
+				"TRIGGER;VALUE=DATE-TIME:20260305T090000Z",
+				"END:VALARM",
+				"END:VTODO",
+			},
+		},
+		{
+			// a finished item needs no reminder
+			name: "completed",
+			item: todo.TodoItem{Id: 8, Title: "Pay rent", Done: true, DueAt: &due, RemindAt: &remind, CompletedAt: &completed,
+				Version: 1, CreatedAt: created, UpdatedAt: updated},
+			want: []string{
+				"BEGIN:VTODO",
+				"UID:item-8@todo-app",
+				"DTSTAMP:20260302T113000Z",
+				"CREATED:20260301T100000Z",
+				"LAST-MODIFIED:20260302T113000Z",
+				"SEQUENCE:0",
+				"SUMMARY:Pay rent",
+				"DUE:20260305T160000Z",
+				"STATUS:COMPLETED",
+				"PERCENT-COMPLETE:100",
+				"COMPLETED:20260304T081500Z",
+				"END:VTODO",
+			},
+		},
+		{
+			name: "long title",
+			item: todo.TodoItem{Id: 9, Title: "Zu " + strings.Repeat("Übung ", 14), DueAt: &due, Priority: todo.PriorityLow,
+				Version: 1, CreatedAt: created, UpdatedAt: updated},
+			want: []string{
+				"BEGIN:VTODO",
+				"UID:item-9@todo-app",
+				"DTSTAMP:20260302T113000Z",
+				"CREATED:20260301T100000Z",
+				"LAST-MODIFIED:20260302T113000Z",
+				"SEQUENCE:0",
+				// the Ü would take octets 75 and 76, so the first line ends before it
+				"SUMMARY:Zu " + strings.Repeat("Übung ", 9),
+				" " + strings.Repeat("Übung ", 5),
+				"PRIORITY:9",
+				"DUE:20260305T160000Z",
+				"STATUS:NEEDS-ACTION",
+				"END:VTODO",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := append(append(append([]string{}, header...), tt.want...), "END:VCALENDAR")
+			want := strings.Join(lines, "\r\n") + "\r\n"
+
+			got := string(encodeCalendar("Home, shared", []todo.TodoItem{tt.item}))
+			if got != want {
+				t.Errorf("got\n%q\nwant\n%q", got, want)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(got, "\r\n"), "\r\n") {
+				if len(line) > calendarLineLimit {
+					t.Errorf("line of %d octets: %q", len(line), line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"time"
+	"todo"
+	"todo/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped.
+const subscriberBuffer = 64
+
+// EventListener receives the events published by all app instances.
+type EventListener interface {
+	Listen(ctx context.Context, handle func(payload []byte)) error
+}
+
+type subscriber struct {
+	userId int
+	events chan todo.Event
+}
+
+// relayedEvent is an event as it travels between app instances.
+type relayedEvent struct {
+	todo.Event
+	Recipients []int `json:"recipients"`
+}
+
+// EventBus passes the changes the services commit on to the subscribed members
+// of the lists concerned. Without a listener events stay within this instance,
+// with one they are relayed through the database to every instance.
+type EventBus struct {
+	repo repository.Events
+
+	mu          sync.Mutex
+	listener    EventListener
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+func NewEventBus(repo repository.Events, listener EventListener) *EventBus {
+	return &EventBus{repo: repo, listener: listener, subscribers: make(map[*subscriber]struct{})}
+}
+
+// Subscribe returns the events of the lists the user is a member of and a
+// function that ends the subscription. The channel is closed when the
+// subscription ends, the subscriber falls behind or the bus stops.
+func (b *EventBus) Subscribe(userId int) (<-chan todo.Event, func()) {
+	s := &subscriber{userId: userId, events: make(chan todo.Event, subscriberBuffer)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(s.events)
+		return s.events, func() {}
+	}
+	b.subscribers[s] = struct{}{}
+
+	return s.events, func() { b.unsubscribe(s) }
+}
+
+func (b *EventBus) unsubscribe(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Run delivers the events relayed by the listener until ctx is done and then
+// ends all subscriptions. If the listener fails, events are delivered within
+// this instance from then on.
+func (b *EventBus) Run(ctx context.Context) {
+	b.mu.Lock()
+	listener := b.listener
+	b.mu.Unlock()
+
+	if listener != nil {
+		if err := listener.Listen(ctx, b.receive); err != nil {
+			logrus.Errorf("error occured while listening for events, delivering them locally: %s", err.Error())
+
+			b.mu.Lock()
+			b.listener = nil
+			b.mu.Unlock()
+		}
+	}
+	<-ctx.Done()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subscribers {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+func (b *EventBus) receive(payload []byte) {
+	var relayed relayedEvent
+	if err := json.Unmarshal(payload, &relayed); err != nil {
+		logrus.Errorf("error occured while decoding event: %s", err.Error())
+		return
+	}
+
+	relayed.Event.Recipients = relayed.Recipients
+	b.deliver(relayed.Event)
+}
+
+// deliver hands the event to the subscriptions of its recipients.
+func (b *EventBus) deliver(event todo.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers {
+		if !slices.Contains(event.Recipients, s.userId) {
+			continue
+		}
+
+		select {
+		case s.events <- event:
+		default:
+			delete(b.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// publish sends events about a list to its members. It is called once the
+// change is committed, so failures are only logged.
+func (b *EventBus) publish(userId, listId int, events ...todo.Event) {
+	recipients, err := b.repo.Recipients(listId)
+	if err != nil {
+		logrus.Errorf("error occured while publishing events: %s", err.Error())
+		return
+	}
+
+	b.mu.Lock()
+	relay := b.listener != nil
+	b.mu.Unlock()
+
+	at := time.Now()
+	for _, event := range events {
+		event.ListId = listId
+		event.UserId = userId
+		event.At = at
+		event.Recipients = recipients
+
+		if !relay {
+			b.deliver(event)
+			continue
+		}
+
+		payload, err := json.Marshal(relayedEvent{Event: event, Recipients: recipients})
+		if err == nil {
+			err = b.repo.Notify(payload)
+		}
+		if err != nil {
+			logrus.Errorf("error occured while relaying event: %s", err.Error())
+			b.deliver(event)
+		}
+	}
+}
+
+func (b *EventBus) listEvent(eventType string, userId, listId int) {
+	b.publish(userId, listId, todo.Event{Type: eventType})
+}
+
+// itemEvent publishes an event about an item to the members of its list.
+func (b *EventBus) itemEvent(eventType string, userId, itemId int) {
+	listId, err := b.repo.ItemList(itemId)
+	if err != nil {
+		logrus.Errorf("error occured while publishing events: %s", err.Error())
+		return
+	}
+
+	b.publish(userId, listId, todo.Event{Type: eventType, ItemId: itemId})
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"todo"
+)
+
+// fakeEventsRepo has fixed members per list and records what is relayed.
+type fakeEventsRepo struct {
+	members map[int][]int
+
+	mu       sync.Mutex
+	notified [][]byte
+}
+
+func (r *fakeEventsRepo) Recipients(listId int) ([]int, error) {
+	return r.members[listId], nil
+}
+
+func (r *fakeEventsRepo) ItemList(itemId int) (int, error) {
+	return 0, errors.New("no items")
+}
+
+func (r *fakeEventsRepo) Notify(payload []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.notified = append(r.notified, payload)
+	return nil
+}
+
+// failingListener cannot listen at all.
+type failingListener struct{}
+
+func (failingListener) Listen(ctx context.Context, handle func(payload []byte)) error {
+	return errors.New("connection refused")
+}
+
+// receive returns the next event of the subscription, failing the test if none
+// arrives in time.
+func receive(t *testing.T, events <-chan todo.Event) todo.Event {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("subscription ended")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+		return todo.Event{}
+	}
+}
+
+func TestEventBusDeliversLocallyWhenListenFails(t *testing.T) {
+	repo := &fakeEventsRepo{members: map[int][]int{1: {10}}}
+	bus := NewEventBus(repo, failingListener{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bus.Run(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		bus.mu.Lock()
+		relaying := bus.listener != nil
+		bus.mu.Unlock()
+		if !relaying {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("bus kept relaying after the listener failed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	events, unsubscribe := bus.Subscribe(10)
+	defer unsubscribe()
+
+	bus.listEvent(todo.EventListUpdated, 10, 1)
+	if event := receive(t, events); event.Type != todo.EventListUpdated || event.ListId != 1 {
+		t.Errorf("event = %+v, want %s of list 1", event, todo.EventListUpdated)
+	}
+	if len(repo.notified) != 0 {
+		t.Errorf("%d events relayed, want none", len(repo.notified))
+	}
+}
+
+func TestEventBusDeliversToRecipients(t *testing.T) {
+	bus := NewEventBus(&fakeEventsRepo{members: map[int][]int{1: {10, 11}, 2: {12}}}, nil)
+
+	member, unsubscribeMember := bus.Subscribe(10)
+	defer unsubscribeMember()
+	otherMember, unsubscribeOther := bus.Subscribe(11)
+	defer unsubscribeOther()
+	outsider, unsubscribeOutsider := bus.Subscribe(12)
+	defer unsubscribeOutsider()
+
+	bus.publish(10, 1, todo.Event{Type: todo.EventItemCreated, ItemId: 5})
+
+	for _, events := range []<-chan todo.Event{member, otherMember} {
+		event := receive(t, events)
+		if event.Type != todo.EventItemCreated || event.ListId != 1 || event.ItemId != 5 || event.UserId != 10 {
+			t.Errorf("event = %+v, want item 5 created in list 1 by user 10", event)
+		}
+	}
+	select {
+	case event := <-outsider:
+		t.Errorf("user 12 received %+v from a list it is not a member of", event)
+	default:
+	}
+}
+
+func TestEventBusEndsSubscriptions(t *testing.T) {
+	bus := NewEventBus(&fakeEventsRepo{members: map[int][]int{1: {10}}}, nil)
+
+	unsubscribed, unsubscribe := bus.Subscribe(10)
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-unsubscribed; ok {
+		t.Error("subscription still open after unsubscribing")
+	}
+
+	events, unsubscribe := bus.Subscribe(10)
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		bus.Run(ctx)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("bus kept running after its context was canceled")
+	}
+	if _, ok := <-events; ok {
+		t.Error("subscription still open after the bus stopped")
+	}
+
+	late, _ := bus.Subscribe(10)
+	if _, ok := <-late; ok {
+		t.Error("subscription to a stopped bus is open")
+	}
+	// publishing to a stopped bus has no one to deliver to
+	bus.listEvent(todo.EventListUpdated, 10, 1)
+}
+
+func TestEventBusDropsSlowSubscribers(t *testing.T) {
+	bus := NewEventBus(&fakeEventsRepo{members: map[int][]int{1: {10}}}, nil)
+
+	// the subscriber never reads
+	events, unsubscribe := bus.Subscribe(10)
+	defer unsubscribe()
+
+	published := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer+10; i++ {
+			bus.publish(10, 1, todo.Event{Type: todo.EventItemUpdated, ItemId: i})
+		}
+		close(published)
+	}()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a subscriber that does not read")
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d events before being dropped, want %d", received, subscriberBuffer)
+	}
+}
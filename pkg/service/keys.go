@@ -0,0 +1,205 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// KeyConfig describes one signing key as it appears in the config file.
+// Secrets are never stored in the config itself: HMAC secrets are read from
+// the environment variable named by SecretEnv, asymmetric keys from PEM files.
+// A key with only a public key can verify tokens but not issue them, which is
+// what a key looks like after it has been rotated out.
+type KeyConfig struct {
+	Id             string `mapstructure:"kid"`
+	Algorithm      string `mapstructure:"alg"`
+	SecretEnv      string `mapstructure:"secret_env"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
+}
+
+type SigningKey struct {
+	Id        string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// KeySet signs tokens with the active key and verifies them with whichever
+// configured key the kid header names, so keys can be rotated without
+// invalidating tokens that are still in flight.
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+}
+
+func NewKeySet(activeId string, configs []KeyConfig) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*SigningKey, len(configs))}
+
+	for _, cfg := range configs {
+		key, err := loadSigningKey(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", cfg.Id, err)
+		}
+		if _, ok := ks.keys[key.Id]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.Id)
+		}
+		ks.keys[key.Id] = key
+	}
+
+	active, ok := ks.keys[activeId]
+	if !ok {
+		return nil, fmt.Errorf("active key %q is not configured", activeId)
+	}
+	if active.SignKey == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeId)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.Id
+
+	return token.SignedString(ks.active.SignKey)
+}
+
+// Keyfunc resolves the verification key for jwt.Parse. The algorithm of the
+// token has to match the one configured for the key, otherwise a public key
+// could be abused as an HMAC secret.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+
+	return key.VerifyKey, nil
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Id        string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes the public halves of the asymmetric keys. HMAC secrets are
+// never part of it, so tokens signed with them can only be verified here.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+
+	for _, key := range ks.keys {
+		switch pub := key.VerifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				KeyType:   "RSA",
+				Id:        key.Id,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				KeyType:   "OKP",
+				Id:        key.Id,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	return jwks
+}
+
+func loadSigningKey(cfg KeyConfig) (*SigningKey, error) {
+	if cfg.Id == "" {
+		return nil, errors.New("kid is required")
+	}
+
+	key := &SigningKey{Id: cfg.Id, Method: jwt.GetSigningMethod(cfg.Algorithm)}
+
+	switch key.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		secret := os.Getenv(cfg.SecretEnv)
+		if cfg.SecretEnv == "" || secret == "" {
+			return nil, errors.New("secret_env must name a non-empty environment variable")
+		}
+		key.SignKey, key.VerifyKey = []byte(secret), []byte(secret)
+
+	case *jwt.SigningMethodRSA:
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.SignKey, key.VerifyKey = private, &private.PublicKey
+		} else if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if key.VerifyKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, errors.New("private_key_file or public_key_file is required")
+		}
+
+	case *jwt.SigningMethodEd25519:
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.SignKey, key.VerifyKey = private, private.(crypto.Signer).Public()
+		} else if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if key.VerifyKey, err = jwt.ParseEdPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, errors.New("private_key_file or public_key_file is required")
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	return key, nil
+}
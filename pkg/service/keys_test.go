@@ -0,0 +1,147 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// writeRSAKey writes a fresh RSA key pair as PEM files and returns their paths
+// together with the PEM of the public key.
+func writeRSAKey(t *testing.T, name string) (string, string, []byte) {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	privateFile := filepath.Join(dir, name+".pem")
+	publicFile := filepath.Join(dir, name+".pub.pem")
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})
+
+	if err := os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(private)}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(publicFile, publicPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return privateFile, publicFile, publicPEM
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestKeySetKeyfuncRejects(t *testing.T) {
+	privateFile, _, publicPEM := writeRSAKey(t, "rsa")
+	t.Setenv("TEST_HMAC_SECRET", "hmac-secret")
+
+	ks, err := NewKeySet("rsa", []KeyConfig{
+		{Id: "rsa", Algorithm: "RS256", PrivateKeyFile: privateFile},
+		{Id: "hmac", Algorithm: "HS256", SecretEnv: "TEST_HMAC_SECRET"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := ks.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(signed, ks.Keyfunc); err != nil {
+		t.Fatalf("token of the active key: %v", err)
+	}
+
+	hs256 := func(kid interface{}, secret []byte) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+		if kid != nil {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		// the public key is no secret, so it must never be accepted as an HMAC key
+		{"HS256 with the kid of an RS256 key", hs256("rsa", publicPEM)},
+		{"unknown kid", hs256("other", []byte("hmac-secret"))},
+		{"missing kid", hs256(nil, []byte("hmac-secret"))},
+		{"kid that is not a string", hs256(1, []byte("hmac-secret"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, _, err := new(jwt.Parser).ParseUnverified(tt.token, jwt.MapClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key, err := ks.Keyfunc(token); err == nil {
+				t.Errorf("Keyfunc returned %T, want an error", key)
+			}
+			if _, err := jwt.Parse(tt.token, ks.Keyfunc); err == nil {
+				t.Error("token verified, want it rejected")
+			}
+		})
+	}
+}
+
+func TestKeySetVerifiesRotatedKey(t *testing.T) {
+	oldPrivate, oldPublic, _ := writeRSAKey(t, "old")
+	newPrivate, _, _ := writeRSAKey(t, "new")
+
+	before, err := NewKeySet("old", []KeyConfig{
+		{Id: "old", Algorithm: "RS256", PrivateKeyFile: oldPrivate},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := before.Sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := NewKeySet("new", []KeyConfig{
+		{Id: "new", Algorithm: "RS256", PrivateKeyFile: newPrivate},
+		{Id: "old", Algorithm: "RS256", PublicKeyFile: oldPublic},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.Parse(signed, after.Keyfunc)
+	if err != nil {
+		t.Fatalf("token of the rotated key: %v", err)
+	}
+	if kid := token.Header["kid"]; kid != "old" {
+		t.Errorf("kid = %v, want old", kid)
+	}
+
+	if _, err := NewKeySet("old", []KeyConfig{
+		{Id: "old", Algorithm: "RS256", PublicKeyFile: oldPublic},
+	}); err == nil {
+		t.Error("verify-only key accepted as the active key")
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"strings"
+	"todo"
+	"todo/pkg/repository"
+)
+
+type LabelService struct {
+	repo repository.Label
+}
+
+func NewLabelService(repo repository.Label) *LabelService {
+	return &LabelService{repo: repo}
+}
+
+func (s *LabelService) Create(userId int, label todo.Label) (int, error) {
+	label.Name = strings.TrimSpace(label.Name)
+	if label.Name == "" {
+		return 0, todo.NewError(todo.ErrValidation, "label name is empty")
+	}
+
+	return s.repo.Create(userId, label)
+}
+
+func (s *LabelService) GetAll(userId int) ([]todo.Label, error) {
+	return s.repo.GetAll(userId)
+}
+
+func (s *LabelService) Delete(userId, labelId int) error {
+	return s.repo.Delete(userId, labelId)
+}
+
+func (s *LabelService) Attach(userId, itemId, labelId int) error {
+	return s.repo.Attach(userId, itemId, labelId)
+}
+
+func (s *LabelService) Detach(userId, itemId, labelId int) error {
+	return s.repo.Detach(userId, itemId, labelId)
+}
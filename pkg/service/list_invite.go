@@ -0,0 +1,104 @@
+package service
+
+import (
+	"time"
+	"todo"
+	"todo/pkg/repository"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	inviteAudience = "list-invite"
+	inviteTTL      = 7 * 24 * time.Hour
+	maxInviteTTL   = 30 * 24 * time.Hour
+)
+
+// inviteClaims only point at an invite row: the role, the use count and
+// revocation live in the database, the signature just makes ids unguessable.
+type inviteClaims struct {
+	jwt.RegisteredClaims
+	InviteId int `json:"invite_id"`
+}
+
+type ListInviteService struct {
+	repo       repository.ListInvite
+	memberRepo repository.ListMember
+	keys       *KeySet
+}
+
+func NewListInviteService(repo repository.ListInvite, memberRepo repository.ListMember, keys *KeySet) *ListInviteService {
+	return &ListInviteService{repo: repo, memberRepo: memberRepo, keys: keys}
+}
+
+func (s *ListInviteService) Create(userId, listId int, input todo.CreateInviteInput) (todo.ListInvite, string, error) {
+	if err := input.Validate(); err != nil {
+		return todo.ListInvite{}, "", err
+	}
+	if err := requireOwner(s.memberRepo, userId, listId); err != nil {
+		return todo.ListInvite{}, "", err
+	}
+
+	invite := todo.ListInvite{
+		ListId:    listId,
+		CreatedBy: userId,
+		Role:      input.Role,
+		MaxUses:   input.MaxUses,
+		ExpiresAt: time.Now().Add(inviteTTL),
+	}
+	if input.ExpiresAt != nil {
+		if input.ExpiresAt.After(time.Now().Add(maxInviteTTL)) {
+			return todo.ListInvite{}, "", todo.NewError(todo.ErrValidation, "invites cannot be valid for more than 30 days")
+		}
+		invite.ExpiresAt = *input.ExpiresAt
+	}
+
+	id, err := s.repo.Create(userId, invite)
+	if err != nil {
+		return todo.ListInvite{}, "", err
+	}
+	invite.Id = id
+
+	token, err := s.keys.Sign(&inviteClaims{
+		jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{inviteAudience},
+			ExpiresAt: jwt.NewNumericDate(invite.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+		id,
+	})
+	if err != nil {
+		return todo.ListInvite{}, "", err
+	}
+
+	return invite, token, nil
+}
+
+func (s *ListInviteService) GetAll(userId, listId int) ([]todo.ListInvite, error) {
+	if err := requireOwner(s.memberRepo, userId, listId); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetAll(listId)
+}
+
+func (s *ListInviteService) Revoke(userId, listId, inviteId int) error {
+	if err := requireOwner(s.memberRepo, userId, listId); err != nil {
+		return err
+	}
+
+	return s.repo.Revoke(listId, inviteId)
+}
+
+func (s *ListInviteService) Accept(userId int, token string) (int, error) {
+	parsed, err := jwt.ParseWithClaims(token, &inviteClaims{}, s.keys.Keyfunc)
+	if err != nil {
+		return 0, todo.NewError(todo.ErrNotFound, "invite is invalid or has expired")
+	}
+	claims, ok := parsed.Claims.(*inviteClaims)
+	if !ok || !claims.VerifyAudience(inviteAudience, true) {
+		return 0, todo.NewError(todo.ErrNotFound, "invite is invalid or has expired")
+	}
+
+	return s.repo.Accept(userId, claims.InviteId)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"todo"
+	"todo/pkg/repository"
+)
+
+type ListMemberService struct {
+	repo repository.ListMember
+}
+
+func NewListMemberService(repo repository.ListMember) *ListMemberService {
+	return &ListMemberService{repo: repo}
+}
+
+func (s *ListMemberService) GetAll(userId, listId int) ([]todo.ListMember, error) {
+	if _, err := s.repo.GetRole(userId, listId); err != nil {
+		// list does not exists or user is not a member
+		return nil, err
+	}
+
+	return s.repo.GetAll(listId)
+}
+
+func (s *ListMemberService) Add(userId, listId int, input todo.AddMemberInput) (int, error) {
+	if err := todo.ValidateRole(input.Role); err != nil {
+		return 0, err
+	}
+	if err := requireOwner(s.repo, userId, listId); err != nil {
+		return 0, err
+	}
+
+	return s.repo.Add(listId, input.Username, input.Role)
+}
+
+func (s *ListMemberService) UpdateRole(userId, listId, memberId int, input todo.UpdateMemberInput) error {
+	if err := todo.ValidateRole(input.Role); err != nil {
+		return err
+	}
+	if err := requireOwner(s.repo, userId, listId); err != nil {
+		return err
+	}
+
+	return s.repo.UpdateRole(listId, memberId, input.Role)
+}
+
+// Remove takes a member off the list. Owners can remove anyone, everybody else
+// can only leave the list themselves.
+func (s *ListMemberService) Remove(userId, listId, memberId int) error {
+	if userId != memberId {
+		if err := requireOwner(s.repo, userId, listId); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.Remove(listId, memberId)
+}
+
+func requireOwner(repo repository.ListMember, userId, listId int) error {
+	role, err := repo.GetRole(userId, listId)
+	if err != nil {
+		return err
+	}
+	if role != todo.RoleOwner {
+		return errForbidden
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package service
+
+import "todo"
+
+// Page sizes used when the configured ones are missing or not positive.
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// Pagination bounds the page sizes clients can ask for.
+type Pagination struct {
+	DefaultLimit int
+	MaxLimit     int
+}
+
+// apply fills in the default page size and caps the requested one at MaxLimit.
+// The result is always at least 1, whatever the configuration.
+func (p Pagination) apply(page todo.Page) todo.Page {
+	maxLimit := p.MaxLimit
+	if maxLimit <= 0 {
+		maxLimit = maxPageLimit
+	}
+	defaultLimit := p.DefaultLimit
+	if defaultLimit <= 0 {
+		defaultLimit = min(defaultPageLimit, maxLimit)
+	}
+
+	if page.Limit <= 0 {
+		page.Limit = defaultLimit
+	}
+	if page.Limit > maxLimit {
+		page.Limit = maxLimit
+	}
+
+	return page
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"testing"
+	"todo"
+)
+
+func TestPaginationApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		pagination Pagination
+		limit      int
+		want       int
+	}{
+		{"default", Pagination{DefaultLimit: 20, MaxLimit: 100}, 0, 20},
+		{"requested", Pagination{DefaultLimit: 20, MaxLimit: 100}, 30, 30},
+		{"capped", Pagination{DefaultLimit: 20, MaxLimit: 100}, 500, 100},
+		{"unconfigured", Pagination{}, 0, defaultPageLimit},
+		{"unconfigured cap", Pagination{}, 500, maxPageLimit},
+		{"negative config", Pagination{DefaultLimit: -1, MaxLimit: -1}, -5, defaultPageLimit},
+		{"default above cap", Pagination{MaxLimit: 10}, 0, 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pagination.apply(todo.Page{Limit: tt.limit}).Limit; got != tt.want {
+				t.Errorf("limit = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const legacySalt = "h328yfuegcvg38974628gcbj"
+
+// PasswordHasher produces self-describing hashes: the algorithm, its parameters
+// and the per-user salt are all encoded in the returned string.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encodedHash string) (bool, error)
+	// NeedsRehash reports whether encodedHash should be replaced, because it was produced
+	// by another algorithm or with parameters other than the hasher's current ones.
+	NeedsRehash(encodedHash string) bool
+}
+
+func NewPasswordHasher(name string) (PasswordHasher, error) {
+	switch name {
+	case "", "argon2id":
+		return newArgon2idHasher(), nil
+	case "bcrypt":
+		return newBcryptHasher(), nil
+	default:
+		return nil, fmt.Errorf("unknown password hasher %q", name)
+	}
+}
+
+// hasherFor picks the hasher able to verify encodedHash. Anything that is not
+// in PHC or modular crypt format is a hash from before the hashers were introduced.
+func hasherFor(encodedHash string) PasswordHasher {
+	switch {
+	case strings.HasPrefix(encodedHash, "$argon2id$"):
+		return newArgon2idHasher()
+	case strings.HasPrefix(encodedHash, "$2a$"), strings.HasPrefix(encodedHash, "$2b$"), strings.HasPrefix(encodedHash, "$2y$"):
+		return newBcryptHasher()
+	default:
+		return legacySHA1Hasher{}
+	}
+}
+
+type argon2idHasher struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+	keyLen  uint32
+	saltLen int
+}
+
+func newArgon2idHasher() *argon2idHasher {
+	return &argon2idHasher{
+		time:    1,
+		memory:  64 * 1024,
+		threads: 4,
+		keyLen:  32,
+		saltLen: 16,
+	}
+}
+
+func (h *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.time, h.memory, h.threads, h.keyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.memory, h.time, h.threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h *argon2idHasher) Verify(password, encodedHash string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *argon2idHasher) NeedsRehash(encodedHash string) bool {
+	params, salt, key, err := decodeArgon2id(encodedHash)
+	if err != nil {
+		return true
+	}
+
+	return params.time != h.time || params.memory != h.memory || params.threads != h.threads ||
+		uint32(len(key)) != h.keyLen || len(salt) != h.saltLen
+}
+
+func decodeArgon2id(encodedHash string) (*argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, nil, err
+	}
+	if version != argon2.Version {
+		return nil, nil, nil, errors.New("incompatible argon2 version")
+	}
+
+	params := &argon2idHasher{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return nil, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return params, salt, key, nil
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func newBcryptHasher() *bcryptHasher {
+	return &bcryptHasher{cost: bcrypt.DefaultCost}
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func (h *bcryptHasher) Verify(password, encodedHash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (h *bcryptHasher) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return err != nil || cost != h.cost
+}
+
+// legacySHA1Hasher only verifies the salted SHA-1 hashes stored before the
+// hashers were introduced; such hashes are upgraded on the next sign-in.
+type legacySHA1Hasher struct{}
+
+func (legacySHA1Hasher) Hash(password string) (string, error) {
+	return "", errors.New("legacy password hashes can only be verified")
+}
+
+func (legacySHA1Hasher) Verify(password, encodedHash string) (bool, error) {
+	hash := sha1.New()
+	hash.Write([]byte(password))
+	expected := fmt.Sprintf("%x", hash.Sum([]byte(legacySalt)))
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(encodedHash)) == 1, nil
+}
+
+func (legacySHA1Hasher) NeedsRehash(encodedHash string) bool {
+	return true
+}
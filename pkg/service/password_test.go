@@ -0,0 +1,157 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"todo"
+	"todo/pkg/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// legacyQwertyHash is "qwerty" as stored by the salted SHA-1 scheme used
+// before the hashers were introduced.
+const legacyQwertyHash = "68333238796675656763766733383937343632386763626ab1b3773a05c0ed0176787a4f1574ff0075f7521e"
+
+func TestPasswordHasherRoundTrip(t *testing.T) {
+	for _, name := range []string{"argon2id", "bcrypt"} {
+		t.Run(name, func(t *testing.T) {
+			hasher, err := NewPasswordHasher(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hash, err := hasher.Hash("qwerty")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := hasherFor(hash).(legacySHA1Hasher); ok {
+				t.Fatalf("hash %q is taken for a legacy hash", hash)
+			}
+
+			ok, err := hasher.Verify("qwerty", hash)
+			if err != nil || !ok {
+				t.Errorf("Verify(right password) = %v, %v, want true", ok, err)
+			}
+			ok, err = hasher.Verify("qwertz", hash)
+			if err != nil || ok {
+				t.Errorf("Verify(wrong password) = %v, %v, want false", ok, err)
+			}
+
+			other, err := hasher.Hash("qwerty")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other == hash {
+				t.Error("hashes of the same password share a salt")
+			}
+
+			if hasher.NeedsRehash(hash) {
+				t.Error("NeedsRehash(current hash) = true, want false")
+			}
+		})
+	}
+}
+
+func TestLegacySHA1HasherVerify(t *testing.T) {
+	hasher := hasherFor(legacyQwertyHash)
+	if _, ok := hasher.(legacySHA1Hasher); !ok {
+		t.Fatalf("hasherFor(legacy hash) = %T, want legacySHA1Hasher", hasher)
+	}
+
+	if ok, err := hasher.Verify("qwerty", legacyQwertyHash); err != nil || !ok {
+		t.Errorf("Verify(right password) = %v, %v, want true", ok, err)
+	}
+	if ok, err := hasher.Verify("qwertz", legacyQwertyHash); err != nil || ok {
+		t.Errorf("Verify(wrong password) = %v, %v, want false", ok, err)
+	}
+	if _, err := hasher.Hash("qwerty"); err == nil {
+		t.Error("Hash succeeded, want legacy hashes to be verify-only")
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weakArgon := newArgon2idHasher()
+	weakArgon.memory = 32 * 1024
+	weakArgonHash, err := weakArgon.Hash("qwerty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cheapBcrypt := &bcryptHasher{cost: bcrypt.MinCost}
+	cheapBcryptHash, err := cheapBcrypt.Hash("qwerty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	argonHash, err := newArgon2idHasher().Hash("qwerty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := newBcryptHasher().Hash("qwerty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		hash   string
+		want   bool
+	}{
+		{"argon2id current", newArgon2idHasher(), argonHash, false},
+		{"argon2id changed params", newArgon2idHasher(), weakArgonHash, true},
+		{"argon2id from bcrypt", newArgon2idHasher(), bcryptHash, true},
+		{"argon2id from legacy", newArgon2idHasher(), legacyQwertyHash, true},
+		{"bcrypt current", newBcryptHasher(), bcryptHash, false},
+		{"bcrypt changed cost", newBcryptHasher(), cheapBcryptHash, true},
+		{"bcrypt from argon2id", newBcryptHasher(), argonHash, true},
+		{"bcrypt from legacy", newBcryptHasher(), legacyQwertyHash, true},
+		{"legacy", legacySHA1Hasher{}, legacyQwertyHash, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// the hash with old parameters still verifies, so it can be upgraded
+	if ok, err := newArgon2idHasher().Verify("qwerty", weakArgonHash); err != nil || !ok {
+		t.Errorf("Verify(changed params) = %v, %v, want true", ok, err)
+	}
+}
+
+// failingRehashRepo cannot store upgraded password hashes.
+type failingRehashRepo struct {
+	repository.Authorization
+
+	updates int
+}
+
+func (r *failingRehashRepo) UpdatePasswordHash(userId int, passwordHash string) error {
+	r.updates++
+	return errors.New("connection reset")
+}
+
+func TestVerifyPasswordIgnoresFailedRehash(t *testing.T) {
+	repo := &failingRehashRepo{}
+	s := NewAuthService(repo, newArgon2idHasher(), nil)
+	user := todo.User{Id: 1, Password: legacyQwertyHash}
+
+	if err := s.verifyPassword(user, "qwerty"); err != nil {
+		t.Errorf("verifyPassword(right password) = %v, want nil", err)
+	}
+	if repo.updates != 1 {
+		t.Errorf("%d rehashes attempted, want 1", repo.updates)
+	}
+
+	if err := s.verifyPassword(user, "qwertz"); !errors.Is(err, errInvalidCredentials) {
+		t.Errorf("verifyPassword(wrong password) = %v, want invalid credentials", err)
+	}
+	if repo.updates != 1 {
+		t.Errorf("%d rehashes attempted, want none for a wrong password", repo.updates-1)
+	}
+}
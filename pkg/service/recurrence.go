@@ -0,0 +1,108 @@
+package service
+
+import (
+	"strings"
+	"time"
+	"todo"
+
+	"github.com/teambition/rrule-go"
+)
+
+var errNoDueDate = todo.NewError(todo.ErrValidation, "recurring items need a due date")
+
+// parseRecurrence parses a single RRULE value, with or without the RRULE: prefix.
+func parseRecurrence(rule string) (*rrule.ROption, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if strings.ContainsAny(rule, "\r\n") {
+		return nil, todo.NewError(todo.ErrValidation, "recurrence must be a single RRULE")
+	}
+
+	option, err := rrule.StrToROption(rule)
+	if err != nil {
+		return nil, todo.NewError(todo.ErrValidation, "invalid recurrence: "+err.Error())
+	}
+	if _, err := rrule.NewRRule(*option); err != nil {
+		return nil, todo.NewError(todo.ErrValidation, "invalid recurrence: "+err.Error())
+	}
+
+	return option, nil
+}
+
+func loadTimezone(timezone *string) (*time.Location, error) {
+	if timezone == nil || *timezone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(*timezone)
+	if err != nil {
+		return nil, todo.NewError(todo.ErrValidation, "unknown timezone "+*timezone)
+	}
+
+	return loc, nil
+}
+
+// validateRecurrence checks the recurrence fields of a new or updated item,
+// empty strings clear them and are always valid.
+func validateRecurrence(recurrence, timezone *string) error {
+	if recurrence != nil && *recurrence != "" {
+		if _, err := parseRecurrence(*recurrence); err != nil {
+			return err
+		}
+	}
+
+	_, err := loadTimezone(timezone)
+	return err
+}
+
+// nextOccurrence returns the item that follows item in its series, or nil when
+// the series has ended. Occurrences are computed from the start of the series in
+// the item's time zone, so wall-clock times survive DST changes and a day of the
+// month missing from some months does not shift the rest of the series.
+func nextOccurrence(item todo.TodoItem) (*todo.TodoItem, error) {
+	if item.Recurrence == nil || item.DueAt == nil {
+		return nil, nil
+	}
+
+	option, err := parseRecurrence(*item.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := loadTimezone(item.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	start := *item.DueAt
+	if item.RecurrenceStart != nil {
+		start = *item.RecurrenceStart
+	}
+	option.Dtstart = start.In(loc)
+
+	rule, err := rrule.NewRRule(*option)
+	if err != nil {
+		return nil, todo.NewError(todo.ErrValidation, "invalid recurrence: "+err.Error())
+	}
+
+	dueAt := rule.After(item.DueAt.In(loc), false)
+	if dueAt.IsZero() {
+		return nil, nil
+	}
+
+	next := todo.TodoItem{
+		ListId:          item.ListId,
+		ParentId:        item.ParentId,
+		Title:           item.Title,
+		Description:     item.Description,
+		DueAt:           &dueAt,
+		Priority:        item.Priority,
+		Recurrence:      item.Recurrence,
+		Timezone:        item.Timezone,
+		RecurrenceStart: &start,
+	}
+	if item.RemindAt != nil {
+		remindAt := dueAt.Add(item.RemindAt.Sub(*item.DueAt))
+		next.RemindAt = &remindAt
+	}
+
+	return &next, nil
+}
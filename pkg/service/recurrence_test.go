@@ -0,0 +1,154 @@
+package service
+
+import (
+	"testing"
+	"time"
+	"todo"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+	newYork := mustLoadLocation(t, "America/New_York")
+
+	tests := []struct {
+		name       string
+		recurrence string
+		timezone   string
+		// start is the start of the series, the due date when zero
+		start time.Time
+		due   time.Time
+		// want is the next due date, zero when the series is over
+		want time.Time
+	}{
+		{
+			name:       "daily into summer time",
+			recurrence: "FREQ=DAILY",
+			timezone:   "Europe/Berlin",
+			due:        time.Date(2026, 3, 28, 9, 0, 0, 0, berlin),
+			want:       time.Date(2026, 3, 29, 9, 0, 0, 0, berlin),
+		},
+		{
+			name:       "daily out of summer time",
+			recurrence: "FREQ=DAILY",
+			timezone:   "America/New_York",
+			due:        time.Date(2026, 10, 31, 8, 0, 0, 0, newYork),
+			want:       time.Date(2026, 11, 1, 8, 0, 0, 0, newYork),
+		},
+		{
+			name:       "daily without a time zone keeps UTC",
+			recurrence: "FREQ=DAILY",
+			due:        time.Date(2026, 3, 28, 8, 0, 0, 0, time.UTC),
+			want:       time.Date(2026, 3, 29, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "31st skips February",
+			recurrence: "FREQ=MONTHLY;BYMONTHDAY=31",
+			due:        time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:       time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "31st after a short month",
+			recurrence: "FREQ=MONTHLY;BYMONTHDAY=31",
+			start:      time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+			due:        time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC),
+			want:       time.Date(2026, 5, 31, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "last day into February",
+			recurrence: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			due:        time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:       time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "last day out of February",
+			recurrence: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start:      time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+			due:        time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC),
+			want:       time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "last day of a leap February",
+			recurrence: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			due:        time.Date(2028, 1, 31, 9, 0, 0, 0, time.UTC),
+			want:       time.Date(2028, 2, 29, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "weekdays skip the weekend",
+			recurrence: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR",
+			timezone:   "Europe/Berlin",
+			due:        time.Date(2026, 10, 16, 9, 0, 0, 0, berlin),
+			want:       time.Date(2026, 10, 19, 9, 0, 0, 0, berlin),
+		},
+		{
+			name:       "weekdays across the weekend the clocks change",
+			recurrence: "RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR",
+			timezone:   "Europe/Berlin",
+			due:        time.Date(2026, 10, 23, 9, 0, 0, 0, berlin),
+			want:       time.Date(2026, 10, 26, 9, 0, 0, 0, berlin),
+		},
+		{
+			name:       "count exhausted",
+			recurrence: "FREQ=DAILY;COUNT=2",
+			start:      time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+			due:        time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "until passed",
+			recurrence: "FREQ=WEEKLY;UNTIL=20260110T000000Z",
+			due:        time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due := tt.due.UTC()
+			remindAt := due.Add(-30 * time.Minute)
+			item := todo.TodoItem{Title: "t", Recurrence: &tt.recurrence, DueAt: &due, RemindAt: &remindAt}
+			if tt.timezone != "" {
+				item.Timezone = &tt.timezone
+			}
+			if !tt.start.IsZero() {
+				start := tt.start.UTC()
+				item.RecurrenceStart = &start
+			}
+
+			next, err := nextOccurrence(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want.IsZero() {
+				if next != nil {
+					t.Fatalf("next due at %v, want the series to be over", next.DueAt)
+				}
+				return
+			}
+			if next == nil {
+				t.Fatal("series is over")
+			}
+			if !next.DueAt.Equal(tt.want) {
+				t.Errorf("due at %v, want %v", next.DueAt, tt.want)
+			}
+			if want := tt.want.Add(-30 * time.Minute); next.RemindAt == nil || !next.RemindAt.Equal(want) {
+				t.Errorf("remind at %v, want %v", next.RemindAt, want)
+			}
+			wantStart := item.DueAt
+			if item.RecurrenceStart != nil {
+				wantStart = item.RecurrenceStart
+			}
+			if !next.RecurrenceStart.Equal(*wantStart) {
+				t.Errorf("series start %v, want %v", next.RecurrenceStart, *wantStart)
+			}
+		})
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s is not available: %s", name, err)
+	}
+	return loc
+}
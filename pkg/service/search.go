@@ -0,0 +1,26 @@
+package service
+
+import (
+	"strings"
+	"todo"
+	"todo/pkg/repository"
+)
+
+type SearchService struct {
+	repo       repository.Search
+	pagination Pagination
+}
+
+func NewSearchService(repo repository.Search, pagination Pagination) *SearchService {
+	return &SearchService{repo: repo, pagination: pagination}
+}
+
+func (s *SearchService) Search(userId int, query string, limit int) ([]todo.SearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, todo.NewError(todo.ErrValidation, "search query is empty")
+	}
+
+	page := s.pagination.apply(todo.Page{Limit: limit})
+	return s.repo.Search(userId, query, page.Limit)
+}
@@ -1,42 +1,181 @@
 package service
 
 import (
+	"context"
+	"time"
 	"todo"
 	"todo/pkg/repository"
 )
 
+var errForbidden = todo.NewError(todo.ErrForbidden, "insufficient permissions for this list")
+
 type Authorization interface {
 	CreateUser(user todo.User) (int, error)
-	GenerateToken(username, password string) (string, error)
+	GenerateToken(username, password string) (Tokens, error)
+	RefreshToken(refreshToken string) (Tokens, error)
+	SignOut(refreshToken string) error
 	ParseToken(token string) (int, error)
+	JWKS() JWKS
 }
 
 type TodoList interface {
 	Create(userId int, list todo.TodoList) (int, error)
-	GetAll(userId int) ([]todo.TodoList, error)
+	GetAll(userId int, filter todo.ListFilter, page todo.Page) ([]todo.TodoList, string, error)
 	GetById(userdId, listId int) (todo.TodoList, error)
-	Delete(userdId, listId int) error
+	Delete(userdId, listId int, version *int) (todo.UndoToken, error)
 	Update(userId, listId int, input todo.UpdateListInput) error
+	Move(userId, listId int, input todo.MoveInput) error
+	Duplicate(userId, listId int, input todo.DuplicateListInput) (int, error)
+	Instantiate(userId, templateId int, input todo.InstantiateTemplateInput) (int, error)
+	Archive(userId, listId int, archived bool) error
+	Restore(userId, listId int) error
 }
 
 type TodoItem interface {
 	Create(userId, listId int, item todo.TodoItem) (int, error)
-	GetAll(userId, listId int) ([]todo.TodoItem, error)
+	GetAll(userId, listId int, filter todo.ItemFilter, page todo.Page) ([]todo.TodoItem, string, error)
 	GetById(userId, itemId int) (todo.TodoItem, error)
-	Delete(userId, itemId int) error
+	GetOverdue(userId int) ([]todo.TodoItem, error)
+	GetUpcoming(userId, days int) ([]todo.TodoItem, error)
+	Delete(userId, itemId int, version *int) (todo.UndoToken, error)
 	Update(userId, itemId int, input todo.UpdateItemInput) error
+	SetParent(userId, itemId int, input todo.SetParentInput) error
+	Move(userId, itemId int, input todo.MoveInput) error
+	MoveToList(userId, itemId int, input todo.TransferItemInput) error
+	CopyToList(userId, itemId int, input todo.TransferItemInput) (int, error)
+	Archive(userId, itemId int, archived bool) error
+	Restore(userId, itemId int) error
+	Bulk(userId, listId int, input todo.BulkInput) ([]todo.BulkResult, todo.UndoToken, error)
+}
+
+type ListMember interface {
+	GetAll(userId, listId int) ([]todo.ListMember, error)
+	Add(userId, listId int, input todo.AddMemberInput) (int, error)
+	UpdateRole(userId, listId, memberId int, input todo.UpdateMemberInput) error
+	Remove(userId, listId, memberId int) error
+}
+
+type ListInvite interface {
+	Create(userId, listId int, input todo.CreateInviteInput) (todo.ListInvite, string, error)
+	GetAll(userId, listId int) ([]todo.ListInvite, error)
+	Revoke(userId, listId, inviteId int) error
+	Accept(userId int, token string) (int, error)
+}
+
+type Label interface {
+	Create(userId int, label todo.Label) (int, error)
+	GetAll(userId int) ([]todo.Label, error)
+	Delete(userId, labelId int) error
+	Attach(userId, itemId, labelId int) error
+	Detach(userId, itemId, labelId int) error
+}
+
+type Search interface {
+	Search(userId int, query string, limit int) ([]todo.SearchResult, error)
+}
+
+type Trash interface {
+	GetAll(userId int) (todo.Trash, error)
+	RunPurger(ctx context.Context, interval time.Duration)
+}
+
+type Webhook interface {
+	Create(userId, listId int, input todo.CreateWebhookInput) (todo.Webhook, error)
+	GetAll(userId, listId int) ([]todo.Webhook, error)
+	Delete(userId, listId, webhookId int) error
+	GetDeliveries(userId, listId, webhookId int, page todo.Page) ([]todo.WebhookDelivery, string, error)
+	Redeliver(userId, listId, webhookId, deliveryId int) (int, error)
+	RunDispatcher(ctx context.Context, interval time.Duration)
+}
+
+type Activity interface {
+	GetByList(userId, listId int, page todo.Page) ([]todo.Activity, string, error)
+	GetFeed(userId int, page todo.Page) ([]todo.Activity, string, error)
+}
+
+type Calendar interface {
+	GetListCalendar(userId, listId int) ([]byte, error)
+	CreateFeed(userId int) (string, error)
+	DeleteFeed(userId int) error
+	GetFeed(token string) ([]byte, error)
+}
+
+type Undo interface {
+	Undo(userId int, token string) (todo.UndoneChange, error)
+}
+
+type Events interface {
+	Subscribe(userId int) (<-chan todo.Event, func())
+	Run(ctx context.Context)
 }
 
 type Service struct {
 	Authorization
 	TodoList
 	TodoItem
+	ListMember
+	ListInvite
+	Label
+	Search
+	Trash
+	Events
+	Webhook
+	Activity
+	Undo
+	Calendar
+}
+
+type Config struct {
+	PasswordHasher PasswordHasher
+	SigningKeys    *KeySet
+	Pagination     Pagination
+	// BulkLimit is the most operations a bulk request may contain,
+	// defaultBulkLimit if it is not positive.
+	BulkLimit int
+	// TrashRetention is how long trashed lists and items can be restored,
+	// defaultTrashRetention if it is not positive.
+	TrashRetention time.Duration
+	// EventListener relays events between app instances, without one they
+	// only reach the subscribers of the instance they happen on.
+	EventListener EventListener
+	Webhooks      WebhookConfig
+	// UndoWindow is how long deletes and bulk changes can be undone,
+	// defaultUndoWindow if it is not positive.
+	UndoWindow time.Duration
 }
 
-func NewService(repos *repository.Repository) *Service {
+func NewService(repos *repository.Repository, cfg Config) *Service {
+	bulkLimit := cfg.BulkLimit
+	if bulkLimit <= 0 {
+		bulkLimit = defaultBulkLimit
+	}
+
+	trashRetention := cfg.TrashRetention
+	if trashRetention <= 0 {
+		trashRetention = defaultTrashRetention
+	}
+
+	undoWindow := cfg.UndoWindow
+	if undoWindow <= 0 {
+		undoWindow = defaultUndoWindow
+	}
+
+	events := NewEventBus(repos.Events, cfg.EventListener)
+	undo := NewUndoService(repos.Journal, undoWindow, events)
+
 	return &Service{
-		Authorization: NewAuthService(repos.Authorization),
-		TodoList:      NewTodoListService(repos.TodoList),
-		TodoItem:      NewTodoItemService(repos.TodoItem, repos.TodoList),
+		Authorization: NewAuthService(repos.Authorization, cfg.PasswordHasher, cfg.SigningKeys),
+		TodoList:      NewTodoListService(repos.TodoList, cfg.Pagination, events, undo),
+		TodoItem:      NewTodoItemService(repos.TodoItem, repos.TodoList, cfg.Pagination, bulkLimit, events, undo),
+		ListMember:    NewListMemberService(repos.ListMember),
+		ListInvite:    NewListInviteService(repos.ListInvite, repos.ListMember, cfg.SigningKeys),
+		Label:         NewLabelService(repos.Label),
+		Search:        NewSearchService(repos.Search, cfg.Pagination),
+		Trash:         NewTrashService(repos.Trash, trashRetention),
+		Events:        events,
+		Webhook:       NewWebhookService(repos.Webhook, repos.ListMember, cfg.Webhooks, cfg.Pagination),
+		Activity:      NewActivityService(repos.Activity, repos.ListMember, cfg.Pagination),
+		Undo:          undo,
+		Calendar:      NewCalendarService(repos.Calendar, repos.TodoList),
 	}
 }
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"testing"
+	"time"
+	"todo/pkg/repository"
+)
+
+func TestNewServiceUndoWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		window time.Duration
+		want   time.Duration
+	}{
+		{"configured", time.Minute, time.Minute},
+		{"unconfigured", 0, defaultUndoWindow},
+		{"negative", -time.Minute, defaultUndoWindow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			undo := NewService(&repository.Repository{}, Config{UndoWindow: tt.window}).Undo.(*UndoService)
+			if undo.window != tt.want {
+				t.Fatalf("window = %v, want %v", undo.window, tt.want)
+			}
+
+			before := time.Now()
+			token, _, err := undo.issue()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !token.ExpiresAt.After(before) {
+				t.Errorf("token expires at %v, before it was issued", token.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestNewServiceTrashRetention(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention time.Duration
+		want      time.Duration
+	}{
+		{"configured", time.Hour, time.Hour},
+		{"unconfigured", 0, defaultTrashRetention},
+		{"negative", -time.Hour, defaultTrashRetention},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trash := NewService(&repository.Repository{}, Config{TrashRetention: tt.retention}).Trash.(*TrashService)
+			if trash.retention != tt.want {
+				t.Errorf("retention = %v, want %v", trash.retention, tt.want)
+			}
+		})
+	}
+}
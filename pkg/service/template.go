@@ -0,0 +1,67 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"time"
+	"todo"
+)
+
+var placeholderPattern = regexp.MustCompile(`{{\s*(\w+)\s*}}`)
+
+// placeholderReplacer returns a function filling in the {{placeholders}} of a
+// template text. Placeholders without a value are kept as they are.
+func placeholderReplacer(values map[string]string, now time.Time) func(string) string {
+	builtins := map[string]string{
+		"date": now.Format("2006-01-02"),
+	}
+
+	return func(text string) string {
+		if !strings.Contains(text, "{{") {
+			return text
+		}
+
+		return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+			name := placeholderPattern.FindStringSubmatch(placeholder)[1]
+			if value, ok := values[name]; ok {
+				return value
+			}
+			if value, ok := builtins[name]; ok {
+				return value
+			}
+			return placeholder
+		})
+	}
+}
+
+func (s *TodoListService) Instantiate(userId, templateId int, input todo.InstantiateTemplateInput) (int, error) {
+	template, err := s.repo.GetById(userId, templateId)
+	if err != nil {
+		return 0, err
+	}
+	if !template.IsTemplate {
+		return 0, todo.NewError(todo.ErrValidation, "list is not a template")
+	}
+
+	replace := placeholderReplacer(input.Values, time.Now())
+
+	list := todo.TodoList{
+		Title:       replace(template.Title),
+		Description: replace(template.Description),
+	}
+	if input.Title != nil {
+		list.Title = *input.Title
+	}
+	if err := checkTitle(list.Title); err != nil {
+		return 0, err
+	}
+
+	// the items are only read in the transaction of the copy, which a title
+	// that is too long after filling in the placeholders rolls back
+	return s.clone(userId, templateId, list, func(item *todo.TodoItem) error {
+		item.Title = replace(item.Title)
+		item.Description = replace(item.Description)
+		item.Done = false
+		return checkTitle(item.Title)
+	})
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"todo"
+	"todo/pkg/repository"
+)
+
+// fakeTemplateRepo clones a template by running edit on copies of its items,
+// the way the copy transaction does.
+type fakeTemplateRepo struct {
+	repository.TodoList
+
+	template todo.TodoList
+	items    []todo.TodoItem
+	cloned   bool
+}
+
+func (r *fakeTemplateRepo) GetById(userId, listId int) (todo.TodoList, error) {
+	return r.template, nil
+}
+
+func (r *fakeTemplateRepo) Clone(userId, listId int, list todo.TodoList, edit func(item *todo.TodoItem) error) (int, error) {
+	for _, item := range r.items {
+		if err := edit(&item); err != nil {
+			return 0, err
+		}
+	}
+	r.cloned = true
+	return 2, nil
+}
+
+func TestInstantiateTitleLength(t *testing.T) {
+	long := strings.Repeat("ü", maxTitleLength+1)
+
+	tests := []struct {
+		name          string
+		templateTitle string
+		itemTitle     string
+		input         todo.InstantiateTemplateInput
+	}{
+		{"list title", "Trip", "Pack", todo.InstantiateTemplateInput{Title: &long}},
+		{"list placeholder", "Trip to {{place}}", "Pack", todo.InstantiateTemplateInput{Values: map[string]string{"place": long}}},
+		{"item placeholder", "Trip", "Pack for {{place}}", todo.InstantiateTemplateInput{Values: map[string]string{"place": long}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeTemplateRepo{
+				template: todo.TodoList{Id: 1, Title: tt.templateTitle, IsTemplate: true},
+				items:    []todo.TodoItem{{Id: 1, Title: tt.itemTitle}},
+			}
+			s := NewTodoListService(repo, Pagination{}, nil, nil)
+
+			if _, err := s.Instantiate(1, 1, tt.input); !errors.Is(err, todo.ErrValidation) {
+				t.Fatalf("err = %v, want a validation error", err)
+			}
+			if repo.cloned {
+				t.Error("template was instantiated")
+			}
+		})
+	}
+}
@@ -1,41 +1,345 @@
 package service
 
 import (
+	"fmt"
+	"time"
 	"todo"
 	"todo/pkg/repository"
 )
 
+const maxUpcomingDays = 365
+
+// defaultBulkLimit is how many operations a bulk request may contain when no
+// limit is configured.
+const defaultBulkLimit = 100
+
 type TodoItemService struct {
-	repo     repository.TodoItem
-	listRepo repository.TodoList
+	repo       repository.TodoItem
+	listRepo   repository.TodoList
+	pagination Pagination
+	bulkLimit  int
+	events     *EventBus
+	undo       *UndoService
 }
 
-func NewTodoItemService(repo repository.TodoItem, listRepo repository.TodoList) *TodoItemService {
-	return &TodoItemService{repo: repo, listRepo: listRepo}
+func NewTodoItemService(repo repository.TodoItem, listRepo repository.TodoList, pagination Pagination, bulkLimit int,
+	events *EventBus, undo *UndoService) *TodoItemService {
+	return &TodoItemService{repo: repo, listRepo: listRepo, pagination: pagination, bulkLimit: bulkLimit, events: events,
+		undo: undo}
 }
 
 func (s *TodoItemService) Create(userId, listId int, item todo.TodoItem) (int, error) {
-	_, err := s.listRepo.GetById(userId, listId)
+	if err := prepareItem(&item); err != nil {
+		return 0, err
+	}
+
+	list, err := s.listRepo.GetById(userId, listId)
 	if err != nil {
 		// list does not exists or does not belongs to user
 		return 0, err
 	}
+	if !todo.CanEdit(list.Role) {
+		return 0, errForbidden
+	}
+
+	itemId, err := s.repo.Create(userId, listId, item)
+	if err != nil {
+		return 0, err
+	}
 
-	return s.repo.Create(listId, item)
+	s.events.publish(userId, listId, todo.Event{Type: todo.EventItemCreated, ItemId: itemId})
+	return itemId, nil
 }
 
-func (s *TodoItemService) GetAll(userId, listId int) ([]todo.TodoItem, error) {
-	return s.repo.GetAll(userId, listId)
+// prepareItem validates a new item and starts its recurrence series.
+func prepareItem(item *todo.TodoItem) error {
+	if err := todo.ValidatePriority(item.Priority); err != nil {
+		return err
+	}
+	if err := validateRecurrence(item.Recurrence, item.Timezone); err != nil {
+		return err
+	}
+	if item.Recurrence != nil && *item.Recurrence == "" {
+		item.Recurrence = nil
+	}
+	if item.Recurrence != nil {
+		if item.DueAt == nil {
+			return errNoDueDate
+		}
+		item.RecurrenceStart = item.DueAt
+	}
+
+	return nil
+}
+
+func (s *TodoItemService) GetAll(userId, listId int, filter todo.ItemFilter, page todo.Page) ([]todo.TodoItem, string, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, "", err
+	}
+	return s.repo.GetAll(userId, listId, filter, s.pagination.apply(page))
 }
 
 func (s *TodoItemService) GetById(userId, itemId int) (todo.TodoItem, error) {
 	return s.repo.GetById(userId, itemId)
 }
 
-func (s *TodoItemService) Delete(userId, itemId int) error {
-	return s.repo.Delete(userId, itemId)
+func (s *TodoItemService) GetOverdue(userId int) ([]todo.TodoItem, error) {
+	now := time.Now()
+	return s.repo.GetDueBetween(userId, nil, &now)
+}
+
+func (s *TodoItemService) GetUpcoming(userId, days int) ([]todo.TodoItem, error) {
+	if days < 1 || days > maxUpcomingDays {
+		return nil, todo.NewError(todo.ErrValidation, "days must be between 1 and 365")
+	}
+
+	from := time.Now()
+	to := from.AddDate(0, 0, days)
+	return s.repo.GetDueBetween(userId, &from, &to)
+}
+
+func (s *TodoItemService) SetParent(userId, itemId int, input todo.SetParentInput) error {
+	if err := s.repo.SetParent(userId, itemId, input.ParentId); err != nil {
+		return err
+	}
+
+	s.events.itemEvent(todo.EventItemUpdated, userId, itemId)
+	return nil
+}
+
+func (s *TodoItemService) Move(userId, itemId int, input todo.MoveInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	if err := s.repo.Move(userId, itemId, input); err != nil {
+		return err
+	}
+
+	s.events.itemEvent(todo.EventItemUpdated, userId, itemId)
+	return nil
+}
+
+// MoveToList moves an item to another list, for the members of the lists the
+// item is deleted from one and created in the other.
+func (s *TodoItemService) MoveToList(userId, itemId int, input todo.TransferItemInput) error {
+	item, err := s.repo.GetById(userId, itemId)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.MoveToList(userId, itemId, input.ListId); err != nil {
+		return err
+	}
+
+	s.events.publish(userId, item.ListId, todo.Event{Type: todo.EventItemDeleted, ItemId: itemId})
+	s.events.publish(userId, input.ListId, todo.Event{Type: todo.EventItemCreated, ItemId: itemId})
+	return nil
+}
+
+func (s *TodoItemService) CopyToList(userId, itemId int, input todo.TransferItemInput) (int, error) {
+	copyId, err := s.repo.CopyToList(userId, itemId, input.ListId)
+	if err != nil {
+		return 0, err
+	}
+
+	s.events.publish(userId, input.ListId, todo.Event{Type: todo.EventItemCreated, ItemId: copyId})
+	return copyId, nil
+}
+
+func (s *TodoItemService) Archive(userId, itemId int, archived bool) error {
+	if err := s.repo.Archive(userId, itemId, archived); err != nil {
+		return err
+	}
+
+	s.events.itemEvent(todo.EventItemUpdated, userId, itemId)
+	return nil
+}
+
+func (s *TodoItemService) Restore(userId, itemId int) error {
+	if err := s.repo.Restore(userId, itemId); err != nil {
+		return err
+	}
+
+	s.events.itemEvent(todo.EventItemCreated, userId, itemId)
+	return nil
+}
+
+// Delete moves the item to the trash and returns the token that undoes it.
+func (s *TodoItemService) Delete(userId, itemId int, version *int) (todo.UndoToken, error) {
+	token, journal, err := s.undo.issue()
+	if err != nil {
+		return todo.UndoToken{}, err
+	}
+
+	if err := s.repo.Delete(userId, itemId, version, journal); err != nil {
+		return todo.UndoToken{}, err
+	}
+
+	s.events.itemEvent(todo.EventItemDeleted, userId, itemId)
+	return token, nil
 }
 
 func (s *TodoItemService) Update(userId, itemId int, input todo.UpdateItemInput) error {
-	return s.repo.Update(userId, itemId, input)
+	if err := s.prepareUpdate(userId, itemId, &input); err != nil {
+		return err
+	}
+
+	if err := s.repo.Update(userId, itemId, input); err != nil {
+		return err
+	}
+
+	s.events.itemEvent(todo.EventItemUpdated, userId, itemId)
+	if input.Next != nil {
+		s.events.itemEvent(todo.EventItemCreated, userId, input.Next.Id)
+	}
+	return nil
+}
+
+// prepareUpdate validates an update and, when it completes a recurring item,
+// adds the next occurrence to it.
+func (s *TodoItemService) prepareUpdate(userId, itemId int, input *todo.UpdateItemInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	if err := validateRecurrence(input.Recurrence, input.Timezone); err != nil {
+		return err
+	}
+
+	setsRecurrence := input.Recurrence != nil && *input.Recurrence != ""
+	completes := input.Done != nil && *input.Done
+	// a recurring item cannot lose its due date
+	clearsDueAt := input.Clears(todo.ClearDueAt)
+	if !setsRecurrence && !completes && !clearsDueAt {
+		return nil
+	}
+
+	item, err := s.repo.GetById(userId, itemId)
+	if err != nil {
+		return err
+	}
+	applyUpdate(&item, *input)
+
+	if item.Recurrence != nil && item.DueAt == nil {
+		return errNoDueDate
+	}
+
+	if completes && !item.Done && item.Recurrence != nil {
+		if input.Recurrence != nil || input.DueAt != nil {
+			// a new rule or due date starts a new series
+			item.RecurrenceStart = item.DueAt
+		}
+		if input.Next, err = nextOccurrence(item); err != nil {
+			return err
+		}
+		// Only the update that completes the version read here may create the
+		// next occurrence, a concurrent completion fails with a precondition error.
+		if input.Version == nil {
+			input.Version = &item.Version
+		}
+	}
+
+	return nil
+}
+
+// Bulk applies a batch of operations to the items of a list, all of them or,
+// if one fails, none, and returns the token that undoes the batch. Updates are
+// prepared against the items as they were before the batch.
+func (s *TodoItemService) Bulk(userId, listId int, input todo.BulkInput) ([]todo.BulkResult, todo.UndoToken, error) {
+	if len(input.Operations) == 0 {
+		return nil, todo.UndoToken{}, todo.NewError(todo.ErrValidation, "operations are required")
+	}
+	if len(input.Operations) > s.bulkLimit {
+		return nil, todo.UndoToken{}, todo.NewError(todo.ErrValidation, fmt.Sprintf("a batch may contain at most %d operations", s.bulkLimit))
+	}
+
+	ops := make([]todo.BulkOperation, len(input.Operations))
+	for i, op := range input.Operations {
+		if err := s.prepareBulk(userId, &op); err != nil {
+			return nil, todo.UndoToken{}, &todo.BulkError{Index: i, Err: err}
+		}
+		ops[i] = op
+	}
+
+	token, journal, err := s.undo.issue()
+	if err != nil {
+		return nil, todo.UndoToken{}, err
+	}
+
+	results, err := s.repo.Bulk(userId, listId, ops, journal)
+	if err != nil {
+		return nil, todo.UndoToken{}, err
+	}
+
+	events := make([]todo.Event, 0, len(results))
+	for i, result := range results {
+		switch result.Op {
+		case todo.BulkCreate:
+			events = append(events, todo.Event{Type: todo.EventItemCreated, ItemId: result.Id})
+		case todo.BulkDelete:
+			events = append(events, todo.Event{Type: todo.EventItemDeleted, ItemId: result.Id})
+		default:
+			events = append(events, todo.Event{Type: todo.EventItemUpdated, ItemId: result.Id})
+			if next := ops[i].Update.Next; next != nil {
+				events = append(events, todo.Event{Type: todo.EventItemCreated, ItemId: next.Id})
+			}
+		}
+	}
+	s.events.publish(userId, listId, events...)
+
+	return results, token, nil
+}
+
+// prepareBulk validates an operation and turns a completion into an update of done.
+func (s *TodoItemService) prepareBulk(userId int, op *todo.BulkOperation) error {
+	if err := op.Validate(); err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case todo.BulkCreate:
+		item := *op.Item
+		op.Item = &item
+		return prepareItem(op.Item)
+	case todo.BulkComplete:
+		done := true
+		op.Update = &todo.UpdateItemInput{Done: &done}
+	case todo.BulkUpdate:
+		update := *op.Update
+		op.Update = &update
+	default:
+		return nil
+	}
+
+	op.Update.Version = op.Version
+	return s.prepareUpdate(userId, op.Id, op.Update)
+}
+
+// applyUpdate sets the fields the input changes on item, except Done.
+func applyUpdate(item *todo.TodoItem, input todo.UpdateItemInput) {
+	if input.Title != nil {
+		item.Title = *input.Title
+	}
+	if input.Description != nil {
+		item.Description = *input.Description
+	}
+	if input.DueAt != nil || input.Clears(todo.ClearDueAt) {
+		item.DueAt = input.DueAt
+	}
+	if input.RemindAt != nil || input.Clears(todo.ClearRemindAt) {
+		item.RemindAt = input.RemindAt
+	}
+	if input.Priority != nil {
+		item.Priority = *input.Priority
+	}
+	if input.Recurrence != nil {
+		item.Recurrence = input.Recurrence
+		if *input.Recurrence == "" {
+			item.Recurrence = nil
+		}
+	}
+	if input.Timezone != nil {
+		item.Timezone = input.Timezone
+		if *input.Timezone == "" {
+			item.Timezone = nil
+		}
+	}
 }
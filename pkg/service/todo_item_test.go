@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"todo"
+)
+
+func TestApplyUpdateClears(t *testing.T) {
+	dueAt := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	remindAt := dueAt.Add(-time.Hour)
+	item := todo.TodoItem{DueAt: &dueAt, RemindAt: &remindAt}
+
+	applyUpdate(&item, todo.UpdateItemInput{Clear: []string{todo.ClearRemindAt}})
+	if item.RemindAt != nil {
+		t.Errorf("remind_at = %v, want cleared", item.RemindAt)
+	}
+	if item.DueAt == nil || !item.DueAt.Equal(dueAt) {
+		t.Errorf("due_at = %v, want %v", item.DueAt, dueAt)
+	}
+
+	applyUpdate(&item, todo.UpdateItemInput{Clear: []string{todo.ClearDueAt}})
+	if item.DueAt != nil {
+		t.Errorf("due_at = %v, want cleared", item.DueAt)
+	}
+}
+
+func TestUpdateItemInputValidateClear(t *testing.T) {
+	dueAt := time.Now()
+	tests := []struct {
+		name    string
+		input   todo.UpdateItemInput
+		wantErr bool
+	}{
+		{"clear only", todo.UpdateItemInput{Clear: []string{todo.ClearDueAt, todo.ClearRemindAt}}, false},
+		{"unknown field", todo.UpdateItemInput{Clear: []string{"title"}}, true},
+		{"set and cleared", todo.UpdateItemInput{DueAt: &dueAt, Clear: []string{todo.ClearDueAt}}, true},
+		{"empty", todo.UpdateItemInput{Clear: []string{}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.input.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBulkLimit(t *testing.T) {
+	s := NewTodoItemService(nil, nil, Pagination{}, 2, nil, nil)
+	op := todo.BulkOperation{Op: todo.BulkDelete, Id: 1}
+
+	tests := []struct {
+		name string
+		ops  []todo.BulkOperation
+	}{
+		{"empty", nil},
+		{"above the limit", []todo.BulkOperation{op, op, op}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the repository is nil, so the batch must be refused before it is used
+			_, _, err := s.Bulk(1, 1, todo.BulkInput{Operations: tt.ops})
+			if !errors.Is(err, todo.ErrValidation) {
+				t.Errorf("err = %v, want a validation error", err)
+			}
+		})
+	}
+}
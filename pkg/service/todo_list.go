@@ -1,37 +1,146 @@
 package service
 
 import (
+	"fmt"
 	"todo"
 	"todo/pkg/repository"
+	"unicode/utf8"
 )
 
+// maxTitleLength is how many characters the title of a list may have.
+const maxTitleLength = 255
+
+const copySuffix = " (copy)"
+
 type TodoListService struct {
-	repo repository.TodoList
+	repo       repository.TodoList
+	pagination Pagination
+	events     *EventBus
+	undo       *UndoService
 }
 
-func NewTodoListService(repo repository.TodoList) *TodoListService {
-	return &TodoListService{repo: repo}
+func NewTodoListService(repo repository.TodoList, pagination Pagination, events *EventBus, undo *UndoService) *TodoListService {
+	return &TodoListService{repo: repo, pagination: pagination, events: events, undo: undo}
 }
 
 func (s *TodoListService) Create(userId int, list todo.TodoList) (int, error) {
-	return s.repo.Create(userId, list)
+	listId, err := s.repo.Create(userId, list)
+	if err != nil {
+		return 0, err
+	}
+
+	s.events.listEvent(todo.EventListCreated, userId, listId)
+	return listId, nil
 }
 
-func (s *TodoListService) GetAll(userId int) ([]todo.TodoList, error) {
-	return s.repo.GetAll(userId)
+func (s *TodoListService) GetAll(userId int, filter todo.ListFilter, page todo.Page) ([]todo.TodoList, string, error) {
+	return s.repo.GetAll(userId, filter, s.pagination.apply(page))
 }
 
 func (s *TodoListService) GetById(userdId, listId int) (todo.TodoList, error) {
 	return s.repo.GetById(userdId, listId)
 }
 
-func (s *TodoListService) Delete(userdId, listId int) error {
-	return s.repo.Delete(userdId, listId)
+// Delete moves the list to the trash and returns the token that undoes it.
+func (s *TodoListService) Delete(userdId, listId int, version *int) (todo.UndoToken, error) {
+	token, journal, err := s.undo.issue()
+	if err != nil {
+		return todo.UndoToken{}, err
+	}
+
+	if err := s.repo.Delete(userdId, listId, version, journal); err != nil {
+		return todo.UndoToken{}, err
+	}
+
+	s.events.listEvent(todo.EventListDeleted, userdId, listId)
+	return token, nil
 }
 
 func (s *TodoListService) Update(userId, listId int, input todo.UpdateListInput) error {
 	if err := input.Validate(); err != nil {
 		return err
 	}
-	return s.repo.Update(userId, listId, input)
+
+	if err := s.repo.Update(userId, listId, input); err != nil {
+		return err
+	}
+
+	s.events.listEvent(todo.EventListUpdated, userId, listId)
+	return nil
+}
+
+func (s *TodoListService) Duplicate(userId, listId int, input todo.DuplicateListInput) (int, error) {
+	list, err := s.repo.GetById(userId, listId)
+	if err != nil {
+		return 0, err
+	}
+
+	list.Title = copyTitle(list.Title)
+	if input.Title != nil {
+		if err := checkTitle(*input.Title); err != nil {
+			return 0, err
+		}
+		list.Title = *input.Title
+	}
+	list.IsTemplate = input.IsTemplate
+
+	return s.clone(userId, listId, list, func(item *todo.TodoItem) error {
+		if input.ResetDone {
+			item.Done = false
+		}
+		return nil
+	})
+}
+
+// checkTitle rejects titles that do not fit the title columns.
+func checkTitle(title string) error {
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return todo.NewError(todo.ErrValidation, fmt.Sprintf("title must be at most %d characters", maxTitleLength))
+	}
+	return nil
+}
+
+func (s *TodoListService) clone(userId, listId int, list todo.TodoList, edit func(item *todo.TodoItem) error) (int, error) {
+	cloneId, err := s.repo.Clone(userId, listId, list, edit)
+	if err != nil {
+		return 0, err
+	}
+
+	s.events.listEvent(todo.EventListCreated, userId, cloneId)
+	return cloneId, nil
+}
+
+func (s *TodoListService) Move(userId, listId int, input todo.MoveInput) error {
+	if err := input.Validate(); err != nil {
+		return err
+	}
+	return s.repo.Move(userId, listId, input)
+}
+
+func (s *TodoListService) Archive(userId, listId int, archived bool) error {
+	if err := s.repo.Archive(userId, listId, archived); err != nil {
+		return err
+	}
+
+	s.events.listEvent(todo.EventListUpdated, userId, listId)
+	return nil
+}
+
+func (s *TodoListService) Restore(userId, listId int) error {
+	if err := s.repo.Restore(userId, listId); err != nil {
+		return err
+	}
+
+	s.events.listEvent(todo.EventListCreated, userId, listId)
+	return nil
+}
+
+// copyTitle is the default title of a copy of a list, the original one cut
+// short if it has to for the suffix to fit.
+func copyTitle(title string) string {
+	limit := maxTitleLength - utf8.RuneCountInString(copySuffix)
+	if runes := []rune(title); len(runes) > limit {
+		title = string(runes[:limit])
+	}
+	return title + copySuffix
 }
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCopyTitle(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		want  string
+	}{
+		{"short", "Groceries", "Groceries (copy)"},
+		{"fits exactly", strings.Repeat("a", maxTitleLength-len(copySuffix)), strings.Repeat("a", maxTitleLength-len(copySuffix)) + copySuffix},
+		{"too long", strings.Repeat("a", maxTitleLength), strings.Repeat("a", maxTitleLength-len(copySuffix)) + copySuffix},
+		{"multibyte", strings.Repeat("ü", maxTitleLength), strings.Repeat("ü", maxTitleLength-len(copySuffix)) + copySuffix},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := copyTitle(tt.title)
+			if got != tt.want {
+				t.Errorf("copyTitle() = %q, want %q", got, tt.want)
+			}
+			if n := utf8.RuneCountInString(got); n > maxTitleLength {
+				t.Errorf("copyTitle() has %d characters", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"time"
+	"todo"
+	"todo/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultTrashRetention is how long trashed lists and items can be restored
+// when no retention is configured.
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+type TrashService struct {
+	repo      repository.Trash
+	retention time.Duration
+}
+
+func NewTrashService(repo repository.Trash, retention time.Duration) *TrashService {
+	return &TrashService{repo: repo, retention: retention}
+}
+
+func (s *TrashService) GetAll(userId int) (todo.Trash, error) {
+	return s.repo.GetAll(userId)
+}
+
+// RunPurger permanently removes whatever has been in the trash for longer than
+// the retention period, once right away and then every interval, until ctx is done.
+// A zero interval disables purging.
+func (s *TrashService) RunPurger(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		logrus.Warn("trash purging is disabled, trashed lists and items are kept forever")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.repo.Purge(time.Now().Add(-s.retention))
+		if err != nil {
+			logrus.Errorf("error occured while purging trash: %s", err.Error())
+		} else if purged > 0 {
+			logrus.Infof("purged %d rows from trash", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+	"todo"
+	"todo/pkg/repository"
+)
+
+// defaultUndoWindow is how long undo tokens are valid when no window is
+// configured.
+const defaultUndoWindow = 5 * time.Minute
+
+// UndoService reverses the changes recorded in the change journal, within the
+// window after them that their undo tokens are valid for.
+type UndoService struct {
+	repo   repository.Journal
+	window time.Duration
+	events *EventBus
+}
+
+func NewUndoService(repo repository.Journal, window time.Duration, events *EventBus) *UndoService {
+	return &UndoService{repo: repo, window: window, events: events}
+}
+
+// issue returns a new undo token and the journal entry a change records it in.
+func (s *UndoService) issue() (todo.UndoToken, todo.Journal, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return todo.UndoToken{}, todo.Journal{}, err
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(b)
+	expiresAt := time.Now().Add(s.window)
+
+	return todo.UndoToken{Token: token, ExpiresAt: expiresAt},
+		todo.Journal{TokenHash: hashUndoToken(token), ExpiresAt: expiresAt}, nil
+}
+
+func (s *UndoService) Undo(userId int, token string) (todo.UndoneChange, error) {
+	change, err := s.repo.Undo(userId, hashUndoToken(token))
+	if err != nil {
+		return todo.UndoneChange{}, err
+	}
+
+	if change.Kind == todo.UndoListDelete {
+		s.events.listEvent(todo.EventListCreated, userId, change.ListId)
+		return change, nil
+	}
+
+	events := make([]todo.Event, 0, len(change.Restored)+len(change.Reverted)+len(change.Removed))
+	for _, itemId := range change.Restored {
+		events = append(events, todo.Event{Type: todo.EventItemCreated, ItemId: itemId})
+	}
+	for _, itemId := range change.Reverted {
+		events = append(events, todo.Event{Type: todo.EventItemUpdated, ItemId: itemId})
+	}
+	for _, itemId := range change.Removed {
+		events = append(events, todo.Event{Type: todo.EventItemDeleted, ItemId: itemId})
+	}
+	s.events.publish(userId, change.ListId, events...)
+
+	return change, nil
+}
+
+// hashUndoToken is what the journal stores, so its rows cannot be used to undo anything.
+func hashUndoToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
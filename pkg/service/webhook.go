@@ -0,0 +1,345 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"todo"
+	"todo/pkg/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Headers of webhook deliveries. The signature is the hex HMAC-SHA256 of the
+// timestamp, a dot and the body, keyed with the secret of the webhook.
+const (
+	webhookEventHeader     = "X-Todo-Event"
+	webhookDeliveryHeader  = "X-Todo-Delivery"
+	webhookTimestampHeader = "X-Todo-Timestamp"
+	webhookSignatureHeader = "X-Todo-Signature"
+)
+
+// maxResponseBody is how much of a response is read before the connection is
+// given up, receivers are only expected to acknowledge.
+const maxResponseBody = 64 << 10
+
+// Delivery settings used when the configured ones are missing or not positive.
+const (
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookMaxAttempts = 8
+	defaultWebhookBackoff     = 30 * time.Second
+	defaultWebhookMaxBackoff  = 6 * time.Hour
+	defaultWebhookBatchSize   = 20
+)
+
+// WebhookConfig tunes the delivery of webhooks.
+type WebhookConfig struct {
+	Timeout     time.Duration
+	MaxAttempts int
+	// Backoff is the delay before the first retry, it doubles with every
+	// retry after that up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	BatchSize  int
+}
+
+type WebhookService struct {
+	repo       repository.Webhook
+	memberRepo repository.ListMember
+	cfg        WebhookConfig
+	pagination Pagination
+	client     *http.Client
+}
+
+func NewWebhookService(repo repository.Webhook, memberRepo repository.ListMember, cfg WebhookConfig, pagination Pagination) *WebhookService {
+	cfg = cfg.withDefaults()
+
+	return &WebhookService{
+		repo:       repo,
+		memberRepo: memberRepo,
+		cfg:        cfg,
+		pagination: pagination,
+		client:     &http.Client{Timeout: cfg.Timeout, Transport: webhookTransport()},
+	}
+}
+
+// withDefaults fills in the settings that are missing or not positive. An
+// unbounded timeout would let one endpoint stall the dispatcher.
+func (c WebhookConfig) withDefaults() WebhookConfig {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultWebhookTimeout
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultWebhookMaxAttempts
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = defaultWebhookBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultWebhookMaxBackoff
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultWebhookBatchSize
+	}
+
+	return c
+}
+
+// nonPublicPrefixes are the addresses outside of the special ones netip knows
+// about that webhooks may not reach: "this network" and carrier-grade NAT.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// webhookTransport connects to public addresses only, so webhooks cannot be
+// pointed at the server itself or its network. The address is checked when it
+// is dialed, after the name of the host is resolved, so it holds for names
+// that resolve to internal addresses and for redirects too. Proxies are not
+// used, as checking their address would say nothing about the target.
+func webhookTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   dialPublicOnly,
+	}
+
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// dialPublicOnly refuses connections to addresses that are not public.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(ip) {
+		return fmt.Errorf("webhook address %s is not public", ip)
+	}
+
+	return nil
+}
+
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// Create registers a webhook and returns it with its secret, which is not
+// returned anywhere else.
+func (s *WebhookService) Create(userId, listId int, input todo.CreateWebhookInput) (todo.Webhook, error) {
+	if err := input.Validate(); err != nil {
+		return todo.Webhook{}, err
+	}
+	if err := requireOwner(s.memberRepo, userId, listId); err != nil {
+		return todo.Webhook{}, err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return todo.Webhook{}, err
+	}
+
+	webhook := todo.Webhook{
+		ListId:    listId,
+		CreatedBy: userId,
+		URL:       input.URL,
+		Secret:    secret,
+		Events:    input.Events,
+		CreatedAt: time.Now(),
+	}
+	if webhook.Id, err = s.repo.Create(webhook); err != nil {
+		return todo.Webhook{}, err
+	}
+
+	return webhook, nil
+}
+
+func (s *WebhookService) GetAll(userId, listId int) ([]todo.Webhook, error) {
+	if err := requireOwner(s.memberRepo, userId, listId); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll(listId)
+}
+
+func (s *WebhookService) Delete(userId, listId, webhookId int) error {
+	if err := requireOwner(s.memberRepo, userId, listId); err != nil {
+		return err
+	}
+	return s.repo.Delete(listId, webhookId)
+}
+
+func (s *WebhookService) GetDeliveries(userId, listId, webhookId int, page todo.Page) ([]todo.WebhookDelivery, string, error) {
+	if err := requireOwner(s.memberRepo, userId, listId); err != nil {
+		return nil, "", err
+	}
+	return s.repo.GetDeliveries(listId, webhookId, s.pagination.apply(page))
+}
+
+func (s *WebhookService) Redeliver(userId, listId, webhookId, deliveryId int) (int, error) {
+	if err := requireOwner(s.memberRepo, userId, listId); err != nil {
+		return 0, err
+	}
+	return s.repo.Redeliver(listId, webhookId, deliveryId)
+}
+
+// RunDispatcher sends the pending deliveries every interval until ctx is done.
+// A zero interval or batch size disables sending.
+func (s *WebhookService) RunDispatcher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 || s.cfg.BatchSize <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// a full batch means there may be more due right away
+		if s.dispatch(ctx) == s.cfg.BatchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch sends a batch of due deliveries and returns how many there were.
+func (s *WebhookService) dispatch(ctx context.Context) int {
+	// a delivery whose attempt is not recorded within the lease is sent again
+	deliveries, err := s.repo.ClaimDue(s.cfg.BatchSize, 2*s.cfg.Timeout+time.Minute)
+	if err != nil {
+		logrus.Errorf("error occured while claiming webhook deliveries: %s", err.Error())
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery todo.WebhookDelivery) {
+			defer wg.Done()
+
+			attempt := s.send(ctx, delivery)
+			if ctx.Err() != nil {
+				// shutting down, the delivery is sent again once its lease is over
+				return
+			}
+			if err := s.repo.RecordAttempt(delivery.Id, attempt); err != nil {
+				logrus.Errorf("error occured while recording webhook delivery %d: %s", delivery.Id, err.Error())
+			}
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(deliveries)
+}
+
+// send posts a delivery to its webhook once and schedules the next attempt
+// if that fails.
+func (s *WebhookService) send(ctx context.Context, delivery todo.WebhookDelivery) todo.DeliveryAttempt {
+	var attempt todo.DeliveryAttempt
+
+	err := s.post(ctx, delivery, &attempt)
+	if err == nil {
+		return attempt
+	}
+
+	message := err.Error()
+	attempt.Error = &message
+	if delivery.Attempts+1 < s.cfg.MaxAttempts {
+		next := time.Now().Add(s.backoff(delivery.Attempts))
+		attempt.NextAttemptAt = &next
+	}
+
+	return attempt
+}
+
+func (s *WebhookService) post(ctx context.Context, delivery todo.WebhookDelivery, attempt *todo.DeliveryAttempt) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TodoApp-Webhooks")
+	req.Header.Set(webhookEventHeader, delivery.EventType)
+	req.Header.Set(webhookDeliveryHeader, strconv.Itoa(delivery.Id))
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "sha256="+signWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	attempt.ResponseStatus = &resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// backoff is the delay before the retry that follows the given number of
+// earlier attempts.
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	delay := s.cfg.Backoff
+	for i := 0; i < attempts && delay < s.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.cfg.MaxBackoff)
+}
+
+func signWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"todo"
+	"todo/pkg/repository"
+)
+
+// fakeWebhookRepo hands out the deliveries it is given once and keeps the
+// attempts recorded for them.
+type fakeWebhookRepo struct {
+	repository.Webhook
+
+	mu       sync.Mutex
+	due      []todo.WebhookDelivery
+	attempts map[int]todo.DeliveryAttempt
+}
+
+func (r *fakeWebhookRepo) ClaimDue(limit int, lease time.Duration) ([]todo.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	due := r.due
+	r.due = nil
+	return due, nil
+}
+
+func (r *fakeWebhookRepo) RecordAttempt(deliveryId int, attempt todo.DeliveryAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.attempts == nil {
+		r.attempts = make(map[int]todo.DeliveryAttempt)
+	}
+	r.attempts[deliveryId] = attempt
+	return nil
+}
+
+var testWebhookConfig = WebhookConfig{
+	Timeout:     5 * time.Second,
+	MaxAttempts: 5,
+	Backoff:     time.Minute,
+	MaxBackoff:  10 * time.Minute,
+	BatchSize:   10,
+}
+
+// newTestWebhookService sends to server, which the address check would refuse.
+func newTestWebhookService(repo repository.Webhook, server *httptest.Server) *WebhookService {
+	s := NewWebhookService(repo, nil, testWebhookConfig, Pagination{})
+	s.client = server.Client()
+	return s
+}
+
+func TestDispatchSignsDeliveries(t *testing.T) {
+	var (
+		header http.Header
+		body   []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	payload := []byte(`{"type":"item.created","item_id":7}`)
+	repo := &fakeWebhookRepo{due: []todo.WebhookDelivery{
+		{Id: 3, EventType: todo.EventItemCreated, Payload: payload, URL: server.URL, Secret: "whsec_test"},
+	}}
+
+	if n := newTestWebhookService(repo, server).dispatch(context.Background()); n != 1 {
+		t.Fatalf("dispatched %d deliveries, want 1", n)
+	}
+
+	if string(body) != string(payload) {
+		t.Errorf("body = %s, want %s", body, payload)
+	}
+	if got := header.Get(webhookEventHeader); got != todo.EventItemCreated {
+		t.Errorf("%s = %q", webhookEventHeader, got)
+	}
+	if got := header.Get(webhookDeliveryHeader); got != "3" {
+		t.Errorf("%s = %q", webhookDeliveryHeader, got)
+	}
+
+	timestamp := header.Get(webhookTimestampHeader)
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Fatalf("%s = %q", webhookTimestampHeader, timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte(timestamp + "." + string(payload)))
+	if got, want := header.Get(webhookSignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("%s = %q, want %q", webhookSignatureHeader, got, want)
+	}
+
+	attempt := repo.attempts[3]
+	if attempt.Error != nil || attempt.NextAttemptAt != nil {
+		t.Errorf("attempt = %+v, want success", attempt)
+	}
+	if attempt.ResponseStatus == nil || *attempt.ResponseStatus != http.StatusOK {
+		t.Errorf("response status = %v, want 200", attempt.ResponseStatus)
+	}
+}
+
+func TestDispatchRetriesFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		attempts int
+		// wantDelay is how long after the attempt the next one is due, zero
+		// when the delivery is given up
+		wantDelay time.Duration
+	}{
+		{0, time.Minute},
+		{1, 2 * time.Minute},
+		{2, 4 * time.Minute},
+		{3, 8 * time.Minute},
+		{4, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.attempts), func(t *testing.T) {
+			repo := &fakeWebhookRepo{due: []todo.WebhookDelivery{
+				{Id: 1, Attempts: tt.attempts, Payload: []byte(`{}`), URL: server.URL, Secret: "s"},
+			}}
+
+			start := time.Now()
+			newTestWebhookService(repo, server).dispatch(context.Background())
+			end := time.Now()
+
+			attempt := repo.attempts[1]
+			if attempt.Error == nil {
+				t.Fatal("attempt has no error")
+			}
+			if attempt.ResponseStatus == nil || *attempt.ResponseStatus != http.StatusInternalServerError {
+				t.Errorf("response status = %v, want 500", attempt.ResponseStatus)
+			}
+
+			if tt.wantDelay == 0 {
+				if attempt.NextAttemptAt != nil {
+					t.Errorf("next attempt at %v, want none after %d attempts", attempt.NextAttemptAt, testWebhookConfig.MaxAttempts)
+				}
+				return
+			}
+			if attempt.NextAttemptAt == nil {
+				t.Fatal("no next attempt")
+			}
+			if next := *attempt.NextAttemptAt; next.Before(start.Add(tt.wantDelay)) || next.After(end.Add(tt.wantDelay)) {
+				t.Errorf("next attempt in %v, want %v", next.Sub(start), tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	s := NewWebhookService(nil, nil, testWebhookConfig, Pagination{})
+	if got := s.backoff(20); got != testWebhookConfig.MaxBackoff {
+		t.Errorf("backoff(20) = %v, want %v", got, testWebhookConfig.MaxBackoff)
+	}
+}
+
+func TestNewWebhookServiceDefaults(t *testing.T) {
+	s := NewWebhookService(nil, nil, WebhookConfig{MaxAttempts: -1}, Pagination{})
+
+	want := WebhookConfig{
+		Timeout:     defaultWebhookTimeout,
+		MaxAttempts: defaultWebhookMaxAttempts,
+		Backoff:     defaultWebhookBackoff,
+		MaxBackoff:  defaultWebhookMaxBackoff,
+		BatchSize:   defaultWebhookBatchSize,
+	}
+	if s.cfg != want {
+		t.Errorf("config = %+v, want %+v", s.cfg, want)
+	}
+	if s.client.Timeout != defaultWebhookTimeout {
+		t.Errorf("client timeout = %v, want %v", s.client.Timeout, defaultWebhookTimeout)
+	}
+
+	if s := NewWebhookService(nil, nil, testWebhookConfig, Pagination{}); s.cfg != testWebhookConfig {
+		t.Errorf("config = %+v, want the configured %+v", s.cfg, testWebhookConfig)
+	}
+}
+
+func TestWebhookClientRefusesInternalAddresses(t *testing.T) {
+	var called bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	repo := &fakeWebhookRepo{due: []todo.WebhookDelivery{
+		{Id: 1, Payload: []byte(`{}`), URL: server.URL, Secret: "s"},
+		{Id: 2, Payload: []byte(`{}`), URL: strings.Replace(server.URL, "127.0.0.1", "localhost", 1), Secret: "s"},
+	}}
+	NewWebhookService(repo, nil, testWebhookConfig, Pagination{}).dispatch(context.Background())
+
+	if called {
+		t.Error("the loopback server was called")
+	}
+	for id, attempt := range repo.attempts {
+		if attempt.Error == nil || !strings.Contains(*attempt.Error, "not public") {
+			t.Errorf("delivery %d error = %v, want the address refused", id, attempt.Error)
+		}
+	}
+	if len(repo.attempts) != 2 {
+		t.Errorf("recorded %d attempts, want 2", len(repo.attempts))
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
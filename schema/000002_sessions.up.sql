@@ -0,0 +1,10 @@
+CREATE TABLE sessions (
+    id serial not null unique,
+    user_id int references users (id) on delete cascade not null,
+    refresh_token_hash varchar(255) not null unique,
+    expires_at timestamp with time zone not null,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone not null default now()
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
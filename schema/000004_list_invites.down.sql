@@ -0,0 +1 @@
+DROP TABLE list_invites;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE list_invites (
+    id serial not null unique,
+    list_id int references todo_lists (id) on delete cascade not null,
+    created_by int references users (id) on delete cascade not null,
+    role varchar(16) not null CHECK (role IN ('owner', 'editor', 'viewer')),
+    max_uses int CHECK (max_uses > 0),
+    uses int not null default 0,
+    expires_at timestamp with time zone not null,
+    revoked_at timestamp with time zone,
+    created_at timestamp with time zone not null default now()
+);
+
+CREATE INDEX list_invites_list_id_idx ON list_invites (list_id);
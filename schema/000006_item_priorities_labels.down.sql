@@ -0,0 +1,4 @@
+DROP TABLE items_labels;
+DROP TABLE labels;
+ALTER TABLE todo_items DROP COLUMN created_at;
+ALTER TABLE todo_items DROP COLUMN priority;
@@ -0,0 +1,18 @@
+ALTER TABLE todo_items ADD COLUMN priority int not null default 0 CHECK (priority BETWEEN 0 AND 3);
+ALTER TABLE todo_items ADD COLUMN created_at timestamp with time zone not null default now();
+
+CREATE TABLE labels (
+    id serial not null unique,
+    user_id int references users (id) on delete cascade not null,
+    name varchar(64) not null,
+    unique (user_id, name)
+);
+
+CREATE TABLE items_labels (
+    id serial not null unique,
+    item_id int references todo_items (id) on delete cascade not null,
+    label_id int references labels (id) on delete cascade not null,
+    unique (item_id, label_id)
+);
+
+CREATE INDEX items_labels_label_id_idx ON items_labels (label_id);
@@ -0,0 +1,3 @@
+DROP INDEX todo_items_created_at_id_idx;
+DROP INDEX todo_lists_created_at_id_idx;
+ALTER TABLE todo_lists DROP COLUMN created_at;
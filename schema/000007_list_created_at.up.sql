@@ -0,0 +1,4 @@
+ALTER TABLE todo_lists ADD COLUMN created_at timestamp with time zone not null default now();
+
+CREATE INDEX todo_lists_created_at_id_idx ON todo_lists (created_at, id);
+CREATE INDEX todo_items_created_at_id_idx ON todo_items (created_at, id);
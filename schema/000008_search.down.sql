@@ -0,0 +1,4 @@
+DROP INDEX todo_items_search_vector_idx;
+DROP INDEX todo_lists_search_vector_idx;
+ALTER TABLE todo_items DROP COLUMN search_vector;
+ALTER TABLE todo_lists DROP COLUMN search_vector;
@@ -0,0 +1,7 @@
+DROP TRIGGER todo_items_updated_at ON todo_items;
+DROP TRIGGER todo_lists_updated_at ON todo_lists;
+DROP FUNCTION set_updated_at();
+
+ALTER TABLE todo_items DROP COLUMN completed_at;
+ALTER TABLE todo_items DROP COLUMN updated_at;
+ALTER TABLE todo_lists DROP COLUMN updated_at;
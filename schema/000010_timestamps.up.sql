@@ -0,0 +1,18 @@
+ALTER TABLE todo_lists ADD COLUMN updated_at timestamp with time zone not null default now();
+ALTER TABLE todo_items ADD COLUMN updated_at timestamp with time zone not null default now();
+ALTER TABLE todo_items ADD COLUMN completed_at timestamp with time zone;
+
+UPDATE todo_lists SET updated_at = created_at;
+UPDATE todo_items SET updated_at = created_at;
+
+CREATE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER todo_lists_updated_at BEFORE UPDATE ON todo_lists
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+CREATE TRIGGER todo_items_updated_at BEFORE UPDATE ON todo_items
+    FOR EACH ROW EXECUTE FUNCTION set_updated_at();
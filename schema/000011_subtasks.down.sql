@@ -0,0 +1 @@
+ALTER TABLE todo_items DROP COLUMN parent_id;
\ No newline at end of file
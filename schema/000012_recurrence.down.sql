@@ -0,0 +1,3 @@
+ALTER TABLE todo_items DROP COLUMN recurrence_start;
+ALTER TABLE todo_items DROP COLUMN timezone;
+ALTER TABLE todo_items DROP COLUMN recurrence;
@@ -0,0 +1,3 @@
+ALTER TABLE todo_items ADD COLUMN recurrence varchar(255);
+ALTER TABLE todo_items ADD COLUMN timezone varchar(64);
+ALTER TABLE todo_items ADD COLUMN recurrence_start timestamp with time zone;
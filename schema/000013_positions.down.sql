@@ -0,0 +1,5 @@
+DROP INDEX users_lists_user_id_position_idx;
+DROP INDEX lists_items_list_id_position_idx;
+
+ALTER TABLE users_lists DROP COLUMN position;
+ALTER TABLE lists_items DROP COLUMN position;
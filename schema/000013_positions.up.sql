@@ -0,0 +1,15 @@
+ALTER TABLE lists_items ADD COLUMN position bigint not null default 0;
+ALTER TABLE users_lists ADD COLUMN position bigint not null default 0;
+
+UPDATE lists_items li SET position = s.rn * 1024 FROM (
+    SELECT li.id, row_number() OVER (PARTITION BY li.list_id ORDER BY ti.created_at, ti.id) AS rn
+    FROM lists_items li INNER JOIN todo_items ti on ti.id = li.item_id
+) s WHERE li.id = s.id;
+
+UPDATE users_lists ul SET position = s.rn * 1024 FROM (
+    SELECT ul.id, row_number() OVER (PARTITION BY ul.user_id ORDER BY tl.created_at, tl.id) AS rn
+    FROM users_lists ul INNER JOIN todo_lists tl on tl.id = ul.list_id
+) s WHERE ul.id = s.id;
+
+CREATE INDEX lists_items_list_id_position_idx ON lists_items (list_id, position);
+CREATE INDEX users_lists_user_id_position_idx ON users_lists (user_id, position);
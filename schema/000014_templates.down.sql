@@ -0,0 +1 @@
+ALTER TABLE todo_lists DROP COLUMN is_template;
\ No newline at end of file
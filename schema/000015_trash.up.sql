@@ -0,0 +1,7 @@
+ALTER TABLE todo_lists ADD COLUMN archived_at timestamp with time zone;
+ALTER TABLE todo_lists ADD COLUMN deleted_at timestamp with time zone;
+ALTER TABLE todo_items ADD COLUMN archived_at timestamp with time zone;
+ALTER TABLE todo_items ADD COLUMN deleted_at timestamp with time zone;
+
+CREATE INDEX todo_lists_deleted_at_idx ON todo_lists (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX todo_items_deleted_at_idx ON todo_items (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,30 @@
+CREATE TABLE webhooks (
+    id serial not null unique,
+    list_id int references todo_lists (id) on delete cascade not null,
+    created_by int references users (id) on delete cascade not null,
+    url varchar(2048) not null,
+    secret varchar(255) not null,
+    events varchar(32)[] not null,
+    created_at timestamp with time zone not null default now()
+);
+
+CREATE INDEX webhooks_list_id_idx ON webhooks (list_id);
+
+-- webhook_deliveries is the outbox: rows are written in the transaction of the
+-- change they report and stay as the delivery log once they are sent.
+CREATE TABLE webhook_deliveries (
+    id bigserial not null unique,
+    webhook_id int references webhooks (id) on delete cascade not null,
+    event_type varchar(32) not null,
+    payload jsonb not null,
+    status varchar(16) not null default 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    attempts int not null default 0,
+    next_attempt_at timestamp with time zone not null default now(),
+    last_attempt_at timestamp with time zone,
+    response_status int,
+    error text,
+    created_at timestamp with time zone not null default now()
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
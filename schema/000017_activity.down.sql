@@ -0,0 +1 @@
+DROP TABLE activity;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE activity (
+    id bigserial not null unique,
+    list_id int references todo_lists (id) on delete cascade not null,
+    user_id int references users (id) on delete set null,
+    action varchar(32) not null,
+    entity varchar(16) not null CHECK (entity IN ('list', 'item')),
+    entity_id int not null,
+    changes jsonb,
+    created_at timestamp with time zone not null default now()
+);
+
+CREATE INDEX activity_list_id_idx ON activity (list_id, id);
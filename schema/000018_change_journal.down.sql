@@ -0,0 +1 @@
+DROP TABLE change_journal;
\ No newline at end of file
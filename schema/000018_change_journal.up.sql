@@ -0,0 +1,13 @@
+CREATE TABLE change_journal (
+    id bigserial not null unique,
+    user_id int references users (id) on delete cascade not null,
+    list_id int references todo_lists (id) on delete cascade not null,
+    token_hash varchar(255) not null unique,
+    kind varchar(32) not null,
+    state jsonb not null,
+    created_at timestamp with time zone not null default now(),
+    expires_at timestamp with time zone not null,
+    undone_at timestamp with time zone
+);
+
+CREATE INDEX change_journal_expires_at_idx ON change_journal (expires_at);
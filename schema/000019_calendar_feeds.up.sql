@@ -0,0 +1,6 @@
+CREATE TABLE calendar_feeds (
+    id serial not null unique,
+    user_id int references users (id) on delete cascade not null unique,
+    token_hash varchar(255) not null unique,
+    created_at timestamp with time zone not null default now()
+);
@@ -0,0 +1,16 @@
+package todo
+
+const (
+	SearchResultList = "list"
+	SearchResultItem = "item"
+)
+
+type SearchResult struct {
+	Type   string `json:"type" db:"type"`
+	Id     int    `json:"id" db:"id"`
+	ListId int    `json:"list_id" db:"list_id"`
+	Title  string `json:"title" db:"title"`
+	// Snippet is HTML: escaped text with the matches wrapped in <mark> tags.
+	Snippet string  `json:"snippet" db:"snippet"`
+	Rank    float64 `json:"rank" db:"rank"`
+}
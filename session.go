@@ -0,0 +1,11 @@
+package todo
+
+import "time"
+
+type Session struct {
+	Id               int        `db:"id"`
+	UserId           int        `db:"user_id"`
+	RefreshTokenHash string     `db:"refresh_token_hash"`
+	ExpiresAt        time.Time  `db:"expires_at"`
+	RevokedAt        *time.Time `db:"revoked_at"`
+}
@@ -1,24 +1,228 @@
 package todo
 
-import "errors"
+import "time"
+
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
 
 type TodoList struct {
-	Id          int    `json:"id" db:"id"`
-	Title       string `json:"title" db:"title" binding:"required"`
-	Description string `json:"description" db:"description"`
+	Id          int        `json:"id" db:"id"`
+	Title       string     `json:"title" db:"title" binding:"required"`
+	Description string     `json:"description" db:"description"`
+	Role        string     `json:"role" db:"role"`
+	Position    int64      `json:"position" db:"position"`
+	IsTemplate  bool       `json:"is_template" db:"is_template"`
+	Version     int        `json:"version" db:"version"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	ArchivedAt  *time.Time `json:"archived_at" db:"archived_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// ListFilter narrows down the lists of a user.
+type ListFilter struct {
+	// Archived returns the archived lists instead of the others.
+	Archived bool
+}
+
+// DuplicateListInput configures the copy of a list, the title defaults to the
+// title of the original followed by (copy).
+type DuplicateListInput struct {
+	Title      *string `json:"title"`
+	ResetDone  bool    `json:"reset_done"`
+	IsTemplate bool    `json:"is_template"`
+}
+
+// InstantiateTemplateInput configures a list made from a template. Values fill
+// in the {{placeholders}} of the titles and descriptions of the template and
+// its items, {{date}} defaults to the current date.
+type InstantiateTemplateInput struct {
+	Title  *string           `json:"title"`
+	Values map[string]string `json:"values"`
 }
 
 type UsersList struct {
 	Id     int
 	UserId int
 	ListId int
+	Role   string
+}
+
+type ListMember struct {
+	UserId   int    `json:"user_id" db:"user_id"`
+	Name     string `json:"name" db:"name"`
+	Username string `json:"username" db:"username"`
+	Role     string `json:"role" db:"role"`
+}
+
+type AddMemberInput struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+type UpdateMemberInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type ListInvite struct {
+	Id        int       `json:"id" db:"id"`
+	ListId    int       `json:"list_id" db:"list_id"`
+	CreatedBy int       `json:"created_by" db:"created_by"`
+	Role      string    `json:"role" db:"role"`
+	MaxUses   *int      `json:"max_uses" db:"max_uses"`
+	Uses      int       `json:"uses" db:"uses"`
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+type CreateInviteInput struct {
+	Role      string     `json:"role" binding:"required"`
+	MaxUses   *int       `json:"max_uses"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (i CreateInviteInput) Validate() error {
+	if err := ValidateRole(i.Role); err != nil {
+		return err
+	}
+	if i.MaxUses != nil && *i.MaxUses < 1 {
+		return NewError(ErrValidation, "max_uses must be positive")
+	}
+	if i.ExpiresAt != nil && !i.ExpiresAt.After(time.Now()) {
+		return NewError(ErrValidation, "expires_at must be in the future")
+	}
+
+	return nil
+}
+
+func ValidateRole(role string) error {
+	switch role {
+	case RoleOwner, RoleEditor, RoleViewer:
+		return nil
+	default:
+		return NewError(ErrValidation, "role must be one of owner, editor, viewer")
+	}
+}
+
+// CanEdit reports whether a member with the role may modify the list and its items.
+func CanEdit(role string) bool {
+	return role == RoleOwner || role == RoleEditor
 }
 
 type TodoItem struct {
-	Id          int    `json:"id" db:"id"`
-	Title       string `json:"title" db:"title" binding:"required"`
-	Description string `json:"description" db:"description"`
-	Done        bool   `json:"done" db:"done"`
+	Id          int        `json:"id" db:"id"`
+	ListId      int        `json:"list_id" db:"list_id"`
+	ParentId    *int       `json:"parent_id" db:"parent_id"`
+	Title       string     `json:"title" db:"title" binding:"required"`
+	Description string     `json:"description" db:"description"`
+	Done        bool       `json:"done" db:"done"`
+	DueAt       *time.Time `json:"due_at" db:"due_at"`
+	RemindAt    *time.Time `json:"remind_at" db:"remind_at"`
+	Priority    int        `json:"priority" db:"priority"`
+	Position    int64      `json:"position" db:"position"`
+	// Recurrence is an iCalendar RRULE such as FREQ=WEEKLY;BYDAY=MO,WE,FR.
+	// Completing a recurring item creates its next occurrence.
+	Recurrence *string `json:"recurrence" db:"recurrence"`
+	// Timezone is the IANA time zone recurrences are computed in, UTC if unset.
+	Timezone        *string    `json:"timezone" db:"timezone"`
+	RecurrenceStart *time.Time `json:"-" db:"recurrence_start"`
+	Labels          []string   `json:"labels" db:"-"`
+	Version         int        `json:"version" db:"version"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	CompletedAt     *time.Time `json:"completed_at" db:"completed_at"`
+	ArchivedAt      *time.Time `json:"archived_at" db:"archived_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	// SubtasksDone and SubtasksTotal roll up the direct subtasks of the item.
+	SubtasksDone  int        `json:"subtasks_done" db:"subtasks_done"`
+	SubtasksTotal int        `json:"subtasks_total" db:"subtasks_total"`
+	Subtasks      []TodoItem `json:"subtasks,omitempty" db:"-"`
+}
+
+// SetParentInput moves an item with all its subtasks under another item of
+// the same list, or to the top level when ParentId is nil.
+type SetParentInput struct {
+	ParentId *int `json:"parent_id"`
+}
+
+// TransferItemInput names the list an item is moved or copied to.
+type TransferItemInput struct {
+	ListId int `json:"list_id" binding:"required"`
+}
+
+// MoveInput places an item or a list right before or right after another one.
+type MoveInput struct {
+	Before *int `json:"before"`
+	After  *int `json:"after"`
+}
+
+func (i MoveInput) Validate() error {
+	if (i.Before == nil) == (i.After == nil) {
+		return NewError(ErrValidation, "exactly one of before and after is required")
+	}
+	return nil
+}
+
+// Anchor returns the id to move next to and whether to move before it.
+func (i MoveInput) Anchor() (int, bool) {
+	if i.Before != nil {
+		return *i.Before, true
+	}
+	return *i.After, false
+}
+
+const (
+	PriorityNone = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+func ValidatePriority(priority int) error {
+	if priority < PriorityNone || priority > PriorityHigh {
+		return NewError(ErrValidation, "priority must be between 0 and 3")
+	}
+	return nil
+}
+
+type Label struct {
+	Id   int    `json:"id" db:"id"`
+	Name string `json:"name" db:"name" binding:"required,max=64"`
+}
+
+const (
+	ItemSortPosition  = "position"
+	ItemSortCreatedAt = "created_at"
+	ItemSortPriority  = "priority"
+	ItemSortDueAt     = "due_at"
+)
+
+// ItemFilter narrows down and orders the items of a list; nil fields do not filter.
+type ItemFilter struct {
+	Done      *bool
+	Priority  *int
+	Label     *string
+	DueBefore *time.Time
+	Sort      string
+	// Tree returns only top-level items matching the filter, each with all
+	// of its subtasks nested in it.
+	Tree bool
+	// Archived returns the archived items instead of the others.
+	Archived bool
+}
+
+func (f ItemFilter) Validate() error {
+	switch f.Sort {
+	case "", ItemSortPosition, ItemSortCreatedAt, ItemSortPriority, ItemSortDueAt:
+	default:
+		return NewError(ErrValidation, "sort must be one of position, priority, due_at, created_at")
+	}
+	if f.Priority != nil {
+		return ValidatePriority(*f.Priority)
+	}
+	return nil
 }
 
 type ListItem struct {
@@ -30,25 +234,78 @@ type ListItem struct {
 type UpdateListInput struct {
 	Title       *string `json:"title"`
 	Description *string `json:"description"`
+	IsTemplate  *bool   `json:"is_template"`
+	// Version, when set, is the version the client last saw; the update
+	// only applies if the list still has it.
+	Version *int `json:"-"`
 }
 
 func (i *UpdateListInput) Validate() error {
-	if i.Title == nil && i.Description == nil {
-		return errors.New("update struscture has no values")
+	if i.Title == nil && i.Description == nil && i.IsTemplate == nil {
+		return NewError(ErrValidation, "update structure has no values")
 	}
 	return nil
 }
 
 type UpdateItemInput struct {
-	Title       *string `json:"title"`
-	Description *string `json:"description"`
-	Done        *bool   `json:"done"`
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	Done        *bool      `json:"done"`
+	DueAt       *time.Time `json:"due_at"`
+	RemindAt    *time.Time `json:"remind_at"`
+	Priority    *int       `json:"priority"`
+	// Recurrence and Timezone are cleared by empty strings.
+	Recurrence *string `json:"recurrence"`
+	Timezone   *string `json:"timezone"`
+	// Clear lists the dates the update removes, as a null date leaves the
+	// date unchanged like any other missing value.
+	Clear []string `json:"clear" enums:"due_at,remind_at"`
+	// Version, when set, is the version the client last saw; the update
+	// only applies if the item still has it.
+	Version *int `json:"-"`
+	// Next, when set, is created along with the update as the next occurrence
+	// of a recurring item.
+	Next *TodoItem `json:"-"`
 }
 
+// Fields an item update can clear.
+const (
+	ClearDueAt    = "due_at"
+	ClearRemindAt = "remind_at"
+)
+
 func (i UpdateItemInput) Validate() error {
-	if i.Title == nil && i.Description == nil && i.Done == nil {
-		return errors.New("update structure has no values")
+	if i.Title == nil && i.Description == nil && i.Done == nil && i.DueAt == nil && i.RemindAt == nil && i.Priority == nil &&
+		i.Recurrence == nil && i.Timezone == nil && len(i.Clear) == 0 {
+		return NewError(ErrValidation, "update structure has no values")
+	}
+	for _, field := range i.Clear {
+		switch field {
+		case ClearDueAt:
+			if i.DueAt != nil {
+				return NewError(ErrValidation, "due_at cannot be both set and cleared")
+			}
+		case ClearRemindAt:
+			if i.RemindAt != nil {
+				return NewError(ErrValidation, "remind_at cannot be both set and cleared")
+			}
+		default:
+			return NewError(ErrValidation, "clear must only contain due_at, remind_at")
+		}
+	}
+	if i.Priority != nil {
+		return ValidatePriority(*i.Priority)
 	}
 
 	return nil
 }
+
+// Clears reports whether the update clears the field.
+func (i UpdateItemInput) Clears(field string) bool {
+	for _, f := range i.Clear {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
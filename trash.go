@@ -0,0 +1,8 @@
+package todo
+
+// Trash holds the lists and items a user can restore. Subtasks trashed along
+// with their parent are restored with it and not listed separately.
+type Trash struct {
+	Lists []TodoList `json:"lists"`
+	Items []TodoItem `json:"items"`
+}
@@ -0,0 +1,33 @@
+package todo
+
+import "time"
+
+// Kinds of changes that can be undone.
+const (
+	UndoListDelete = "list.delete"
+	UndoItemDelete = "item.delete"
+	UndoBulk       = "items.bulk"
+)
+
+// UndoToken reverses a change with POST /api/undo/{token} until it expires.
+type UndoToken struct {
+	Token     string    `json:"undo_token"`
+	ExpiresAt time.Time `json:"undo_expires_at"`
+}
+
+// Journal is the entry of the change journal a change records how to reverse
+// it in. Only the hash of the undo token is stored.
+type Journal struct {
+	TokenHash string
+	ExpiresAt time.Time
+}
+
+// UndoneChange is what undoing a change did: the items it brought back from
+// the trash, removed again and set back to how they were.
+type UndoneChange struct {
+	Kind     string `json:"kind"`
+	ListId   int    `json:"list_id"`
+	Restored []int  `json:"restored,omitempty"`
+	Removed  []int  `json:"removed,omitempty"`
+	Reverted []int  `json:"reverted,omitempty"`
+}
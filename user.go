@@ -2,7 +2,7 @@ package todo
 
 type User struct {
 	Id       int    `json:"-" db:"id"`
-	Name     string `json:"name" binding:"required"`
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Name     string `json:"name" db:"name" binding:"required"`
+	Username string `json:"username" db:"username" binding:"required"`
+	Password string `json:"password" db:"password_hash" binding:"required"`
 }
@@ -0,0 +1,92 @@
+package todo
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookEvents are the event types webhooks can subscribe to.
+var WebhookEvents = []string{EventItemCreated, EventItemUpdated, EventItemDeleted}
+
+// Webhook is a URL the changes to the items of a list are posted to. The
+// secret signs the deliveries and is only returned when the webhook is created.
+type Webhook struct {
+	Id        int       `json:"id" db:"id"`
+	ListId    int       `json:"list_id" db:"list_id"`
+	CreatedBy int       `json:"created_by" db:"created_by"`
+	URL       string    `json:"url" db:"url"`
+	Secret    string    `json:"secret,omitempty" db:"secret"`
+	Events    []string  `json:"events" db:"-"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type CreateWebhookInput struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events" binding:"required"`
+}
+
+func (i CreateWebhookInput) Validate() error {
+	u, err := url.Parse(i.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return NewError(ErrValidation, "url must be an absolute http or https URL")
+	}
+	if len(i.Events) == 0 {
+		return NewError(ErrValidation, "events are required")
+	}
+	for _, event := range i.Events {
+		if !isWebhookEvent(event) {
+			return NewError(ErrValidation, "events must be among item.created, item.updated, item.deleted")
+		}
+	}
+	return nil
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookPayload is the body of a delivery: the event and, unless the item is
+// gone for good, the item as it was right after the change.
+type WebhookPayload struct {
+	Event
+	Item *TodoItem `json:"item,omitempty"`
+}
+
+// WebhookDelivery is a payload to send to a webhook and the log of the
+// attempts to send it.
+type WebhookDelivery struct {
+	Id             int             `json:"id" db:"id"`
+	WebhookId      int             `json:"webhook_id" db:"webhook_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload" swaggertype:"object"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at" db:"last_attempt_at"`
+	ResponseStatus *int            `json:"response_status" db:"response_status"`
+	Error          *string         `json:"error" db:"error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	// URL and Secret are those of the webhook, for sending.
+	URL    string `json:"-" db:"url"`
+	Secret string `json:"-" db:"secret"`
+}
+
+// DeliveryAttempt is the outcome of sending a delivery once.
+type DeliveryAttempt struct {
+	ResponseStatus *int
+	Error          *string
+	// NextAttemptAt is when to try again after a failure, nil to give up.
+	NextAttemptAt *time.Time
+}